@@ -0,0 +1,241 @@
+// Package articleio imports and exports articles as Markdown files with YAML
+// front matter, one article per file.
+package articleio
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/koyoyo/realworld-starter-kit/markdown"
+	"github.com/koyoyo/realworld-starter-kit/models"
+)
+
+// maxFileSize caps each Markdown file read from a directory or ZIP.
+const maxFileSize = 1 << 20
+
+// A ZIP archive may hold at most maxZipEntries entries and maxZipSize bytes
+// of Markdown once decompressed, so that a small upload can't expand into
+// an unbounded amount of memory.
+const (
+	maxZipEntries = 1000
+	maxZipSize    = 32 << 20
+)
+
+// File is one Markdown document to import.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Result reports what happened to one imported file.
+type Result struct {
+	File  string `json:"file"`
+	Slug  string `json:"slug,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Options tune an import.
+type Options struct {
+	// Check screens each article before it is created, typically with a
+	// moderation.ContentFilter.
+	Check func(texts ...string) error
+	// KeepFutureDates allows dates after the import; otherwise they are
+	// replaced by the import time.
+	KeepFutureDates bool
+}
+
+func isMarkdown(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// ReadDir reads the Markdown files of a directory and its subdirectories.
+func ReadDir(dir string) ([]File, error) {
+	var files []File
+	err := filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !isMarkdown(name) {
+			return err
+		}
+		if info.Size() > maxFileSize {
+			return errors.New(name + " is too large")
+		}
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, name)
+		files = append(files, File{Name: filepath.ToSlash(rel), Data: data})
+		return nil
+	})
+	return files, err
+}
+
+// ReadZip reads the Markdown files of a ZIP archive.
+func ReadZip(r io.ReaderAt, size int64) ([]File, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(archive.File) > maxZipEntries {
+		return nil, fmt.Errorf("archive has more than %d entries", maxZipEntries)
+	}
+
+	var files []File
+	var total int64
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || !isMarkdown(entry.Name) {
+			continue
+		}
+
+		f, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(f, maxFileSize+1))
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxFileSize {
+			return nil, errors.New(entry.Name + " is too large")
+		}
+		// Sizes in the headers can lie, so count what was actually read.
+		total += int64(len(data))
+		if total > maxZipSize {
+			return nil, fmt.Errorf("archive expands to more than %d bytes", maxZipSize)
+		}
+		files = append(files, File{Name: entry.Name, Data: data})
+	}
+	return files, nil
+}
+
+// ReadZipFile reads the Markdown files of the ZIP archive at path.
+func ReadZipFile(path string) ([]File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return ReadZip(f, info.Size())
+}
+
+// Import creates an article of authorID for each file. A file that fails
+// doesn't stop the others; its Result carries the error.
+func Import(db *models.DB, authorID uint, files []File, options Options) []*Result {
+	results := []*Result{}
+	for _, file := range files {
+		result := &Result{File: file.Name}
+		if article, err := importFile(db, authorID, file, options); err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Slug = article.Slug
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func importFile(db *models.DB, authorID uint, file File, options Options) (*models.Article, error) {
+	fm, body, err := markdown.ParseFrontMatter(file.Data)
+	if err != nil {
+		return nil, err
+	}
+	if fm.Title == "" {
+		return nil, errors.New("title is required")
+	}
+	if strings.TrimSpace(body) == "" {
+		return nil, errors.New("body is required")
+	}
+
+	date, err := fm.Time()
+	if err != nil {
+		return nil, err
+	}
+	if !options.KeepFutureDates && date.After(time.Now()) {
+		date = time.Time{}
+	}
+
+	if options.Check != nil {
+		if err := options.Check(fm.Title, fm.Description, body); err != nil {
+			return nil, err
+		}
+	}
+
+	return db.ImportArticle(authorID, fm.Title, fm.Description, body, fm.Tags, fm.Slug, date)
+}
+
+// Format renders an article as Markdown with front matter.
+func Format(article *models.Article) ([]byte, error) {
+	var tags []string
+	for _, tag := range article.Tag {
+		tags = append(tags, tag.Name)
+	}
+
+	return markdown.FormatFrontMatter(&markdown.FrontMatter{
+		Title:       article.Title,
+		Description: article.Description,
+		Tags:        tags,
+		Date:        article.CreatedAt.UTC().Format(time.RFC3339),
+		Slug:        article.Slug,
+	}, article.Body)
+}
+
+// ExportZip writes the articles of authorID to w as a ZIP of <slug>.md files.
+func ExportZip(db *models.DB, authorID uint, w io.Writer) error {
+	archive := zip.NewWriter(w)
+	for _, article := range db.ListAuthorArticles(authorID) {
+		data, err := Format(article)
+		if err != nil {
+			return err
+		}
+
+		f, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     article.Slug + ".md",
+			Method:   zip.Deflate,
+			Modified: article.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// ExportDir writes the articles of authorID to dir as <slug>.md files.
+func ExportDir(db *models.DB, authorID uint, dir string) (int, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+
+	articles := db.ListAuthorArticles(authorID)
+	for _, article := range articles {
+		data, err := Format(article)
+		if err != nil {
+			return 0, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, article.Slug+".md"), data, 0644); err != nil {
+			return 0, err
+		}
+	}
+	return len(articles), nil
+}
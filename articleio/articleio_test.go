@@ -0,0 +1,57 @@
+package articleio
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func zipOf(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(data))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadZipLimits(t *testing.T) {
+	tests := map[string]struct {
+		files map[string]string
+		ok    bool
+	}{
+		"small": {map[string]string{"a.md": "# A", "b.txt": "skipped"}, true},
+		"too many entries": {func() map[string]string {
+			files := map[string]string{}
+			for i := 0; i <= maxZipEntries; i++ {
+				files[fmt.Sprintf("%d.txt", i)] = ""
+			}
+			return files
+		}(), false},
+		"too large once decompressed": {func() map[string]string {
+			files := map[string]string{}
+			for i := 0; i <= maxZipSize/maxFileSize; i++ {
+				files[fmt.Sprintf("%d.md", i)] = strings.Repeat("a", maxFileSize)
+			}
+			return files
+		}(), false},
+		"one file too large": {map[string]string{"a.md": strings.Repeat("a", maxFileSize+1)}, false},
+	}
+
+	for name, test := range tests {
+		data := zipOf(t, test.files)
+		files, err := ReadZip(bytes.NewReader(data), int64(len(data)))
+		if (err == nil) != test.ok {
+			t.Errorf("%s: ReadZip(%d compressed bytes) = %d files, %v; want ok=%v", name, len(data), len(files), err, test.ok)
+		}
+	}
+}
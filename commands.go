@@ -0,0 +1,334 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	validator "gopkg.in/go-playground/validator.v9"
+
+	"github.com/koyoyo/realworld-starter-kit/articleio"
+	"github.com/koyoyo/realworld-starter-kit/config"
+	"github.com/koyoyo/realworld-starter-kit/jobs"
+	"github.com/koyoyo/realworld-starter-kit/models"
+	"github.com/koyoyo/realworld-starter-kit/seed"
+)
+
+// command is a subcommand of the binary. Every command but help runs after
+// the configuration is loaded and validated and the database is open.
+type command struct {
+	Usage string
+	Short string
+	Run   func(cfg *config.Config, db *models.DB, args []string)
+}
+
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"serve":   {"serve [-migrate=false]", "run the API server (the default)", serve},
+		"migrate": {"migrate", "migrate the schema and run pending data migrations", migrate},
+		"seed": {"seed [-force] [-seed n] [-users n] [-articles n] [-follows n] [-favorites n] [-comments n] [-password s]",
+			"fill a development database with generated data", seedDatabase},
+		"user":    {"user create|promote|demote|reset-password ...", "manage users", userCommand},
+		"token":   {"token issue [-ttl 24h] <username>", "issue a JWT for a user", tokenCommand},
+		"reindex": {"reindex", "rebuild rendered Markdown, counters, trending scores and recommendations", reindex},
+
+		"reconcile-favorites":       {"reconcile-favorites", "recompute favoritesCount from the favorites", reconcileFavorites},
+		"refresh-trending":          {"refresh-trending", "recompute the trending scores", refreshTrending},
+		"recompute-recommendations": {"recompute-recommendations", "recompute related articles and recommendations", recomputeRecommendations},
+		"purge-trash":               {"purge-trash", "delete trashed articles and comments past retention", purgeTrash},
+		"import-articles":           {"import-articles <username> <directory or .zip>", "import Markdown articles", articlesCommand("import-articles")},
+		"export-articles":           {"export-articles <username> <directory or .zip>", "export a user's articles as Markdown", articlesCommand("export-articles")},
+		"help":                      {"help", "show this help", nil},
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] <command> [arguments]\n\nCommands:\n", os.Args[0])
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-28s %s\n", name, commands[name].Short)
+		fmt.Fprintf(os.Stderr, "  %-28s   %s %s\n", "", os.Args[0], commands[name].Usage)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nFlags, which beat the environment and the config file:\n")
+	flags := config.NewFlagSet(os.Args[0])
+	flags.SetOutput(os.Stderr)
+	flags.PrintDefaults()
+}
+
+// usageError reports wrong arguments to a command and exits.
+func usageError(name string) {
+	fmt.Fprintf(os.Stderr, "Usage: %s %s\n", os.Args[0], commands[name].Usage)
+	os.Exit(2)
+}
+
+// lookupUser returns the user named username, exiting when there is none.
+func lookupUser(db *models.DB, username string) *models.User {
+	user := db.GetUserFromUsername(username).User
+	if user.ID == 0 {
+		fmt.Fprintf(os.Stderr, "Unknown user: %s\n", username)
+		os.Exit(1)
+	}
+	return &user
+}
+
+func migrate(cfg *config.Config, db *models.DB, args []string) {
+	db.Migrate()
+	fmt.Println("Migrated the schema")
+}
+
+func reconcileFavorites(cfg *config.Config, db *models.DB, args []string) {
+	fixed, err := db.ReconcileFavoritesCount()
+	if err != nil {
+		panic(fmt.Errorf("Reconcile favorites: %s \n", err))
+	}
+	fmt.Printf("Reconciled favoritesCount on %d articles\n", fixed)
+}
+
+func refreshTrending(cfg *config.Config, db *models.DB, args []string) {
+	trending := jobs.NewTrending(db)
+	refreshed, err := db.RefreshTrendingScores(trending.HalfLife)
+	if err != nil {
+		panic(fmt.Errorf("Refresh trending: %s \n", err))
+	}
+	fmt.Printf("Refreshed trending score on %d articles\n", refreshed)
+}
+
+func recomputeRecommendations(cfg *config.Config, db *models.DB, args []string) {
+	related, recommended, err := db.RecomputeRecommendations()
+	if err != nil {
+		panic(fmt.Errorf("Recompute recommendations: %s \n", err))
+	}
+	fmt.Printf("Recomputed %d related articles and %d recommendations\n", related, recommended)
+}
+
+func purgeTrash(cfg *config.Config, db *models.DB, args []string) {
+	articles, comments, err := db.PurgeTrash(time.Now().Add(-cfg.TrashRetention))
+	if err != nil {
+		panic(fmt.Errorf("Purge trash: %s \n", err))
+	}
+	fmt.Printf("Purged %d articles and %d comments\n", articles, comments)
+}
+
+// reindex rebuilds everything derived from the stored content.
+func reindex(cfg *config.Config, db *models.DB, args []string) {
+	articles, comments, err := db.RenderMarkdown()
+	if err != nil {
+		panic(fmt.Errorf("Render markdown: %s \n", err))
+	}
+	fmt.Printf("Rendered %d articles and %d comments\n", articles, comments)
+
+	reconcileFavorites(cfg, db, nil)
+	fixed, err := db.ReconcileCommentsCount()
+	if err != nil {
+		panic(fmt.Errorf("Reconcile comments: %s \n", err))
+	}
+	fmt.Printf("Reconciled commentsCount on %d articles\n", fixed)
+
+	refreshTrending(cfg, db, nil)
+	recomputeRecommendations(cfg, db, nil)
+}
+
+func articlesCommand(name string) func(cfg *config.Config, db *models.DB, args []string) {
+	return func(cfg *config.Config, db *models.DB, args []string) {
+		if len(args) != 2 {
+			usageError(name)
+		}
+		author := lookupUser(db, args[0])
+
+		if name == "import-articles" {
+			importArticles(db, author.ID, args[1])
+		} else {
+			exportArticles(db, author.ID, args[1])
+		}
+	}
+}
+
+// userCommand creates users, changes their role or resets their password.
+func userCommand(cfg *config.Config, db *models.DB, args []string) {
+	if len(args) == 0 {
+		usageError("user")
+	}
+
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("user create", flag.ExitOnError)
+		username := flags.String("username", "", "username")
+		email := flags.String("email", "", "email address")
+		password := flags.String("password", "", "password, generated when empty")
+		admin := flags.Bool("admin", false, "grant the admin role")
+		flags.Parse(args[1:])
+
+		if *username == "" || validator.New().Var(*email, "required,email") != nil {
+			fmt.Fprintf(os.Stderr, "Usage: %s user create -username <name> -email <address> [-password <password>] [-admin]\n", os.Args[0])
+			os.Exit(2)
+		}
+		if models.IsReservedUsername(*username) {
+			fmt.Fprintf(os.Stderr, "Usernames starting with %s are reserved\n", models.DeletedUserPrefix)
+			os.Exit(1)
+		}
+		if db.GetUserFromUsername(*username).User.ID != 0 || db.GetUserFromEmail(*email).User.ID != 0 {
+			fmt.Fprintf(os.Stderr, "A user with this username or email already exists\n")
+			os.Exit(1)
+		}
+
+		generated := *password == ""
+		if generated {
+			*password = randomPassword()
+		}
+		user := db.CreateUser(*username, *email, *password).User
+		if *admin {
+			db.SetAdmin(&user, true)
+		}
+		fmt.Printf("Created user %s (id %d)\n", user.Username, user.ID)
+		if generated {
+			fmt.Printf("Password: %s\n", *password)
+		}
+	case "promote", "demote":
+		if len(args) != 2 {
+			fmt.Fprintf(os.Stderr, "Usage: %s user %s <username>\n", os.Args[0], args[0])
+			os.Exit(2)
+		}
+		user := lookupUser(db, args[1])
+		db.SetAdmin(user, args[0] == "promote")
+		fmt.Printf("%s admin: %t\n", user.Username, user.Admin)
+	case "reset-password":
+		flags := flag.NewFlagSet("user reset-password", flag.ExitOnError)
+		password := flags.String("password", "", "new password, generated when empty")
+		flags.Parse(args[1:])
+		if flags.NArg() != 1 {
+			fmt.Fprintf(os.Stderr, "Usage: %s user reset-password [-password <password>] <username>\n", os.Args[0])
+			os.Exit(2)
+		}
+
+		user := lookupUser(db, flags.Arg(0))
+		generated := *password == ""
+		if generated {
+			*password = randomPassword()
+		}
+		db.UpdateUser(user, "", "", *password, "", nil)
+		// Whoever knew the old password may still hold a token.
+		db.RevokeTokens(user)
+		fmt.Printf("Reset the password of %s and revoked their tokens\n", user.Username)
+		if generated {
+			fmt.Printf("Password: %s\n", *password)
+		}
+	default:
+		usageError("user")
+	}
+}
+
+// tokenCommand issues a JWT, e.g. for scripts calling the API as a user.
+func tokenCommand(cfg *config.Config, db *models.DB, args []string) {
+	if len(args) == 0 || args[0] != "issue" {
+		usageError("token")
+	}
+
+	flags := flag.NewFlagSet("token issue", flag.ExitOnError)
+	ttl := flags.Duration("ttl", 24*time.Hour, "lifetime of the token")
+	flags.Parse(args[1:])
+	if flags.NArg() != 1 {
+		usageError("token")
+	}
+
+	user := lookupUser(db, flags.Arg(0))
+	fmt.Println(models.GenerateTokenWithTTL([]byte(cfg.JWTSignedKey), user.Username, user.ID, *ttl))
+}
+
+func randomPassword() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Errorf("Random password: %s \n", err))
+	}
+	return hex.EncodeToString(buf)
+}
+
+// importArticles imports a directory or ZIP of Markdown files. Unlike the
+// API it keeps dates set in the future.
+func importArticles(db *models.DB, authorID uint, path string) {
+	var files []articleio.File
+	var err error
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		files, err = articleio.ReadZipFile(path)
+	} else {
+		files, err = articleio.ReadDir(path)
+	}
+	if err != nil {
+		panic(fmt.Errorf("Import articles: %s \n", err))
+	}
+
+	failed := 0
+	for _, result := range articleio.Import(db, authorID, files, articleio.Options{KeepFutureDates: true}) {
+		if result.Error != "" {
+			failed++
+			fmt.Printf("%s: %s\n", result.File, result.Error)
+		} else {
+			fmt.Printf("%s -> %s\n", result.File, result.Slug)
+		}
+	}
+	fmt.Printf("Imported %d articles, %d failed\n", len(files)-failed, failed)
+}
+
+// exportArticles writes the author's articles to a directory, or to a ZIP
+// when path ends in .zip.
+func exportArticles(db *models.DB, authorID uint, path string) {
+	if !strings.HasSuffix(strings.ToLower(path), ".zip") {
+		count, err := articleio.ExportDir(db, authorID, path)
+		if err != nil {
+			panic(fmt.Errorf("Export articles: %s \n", err))
+		}
+		fmt.Printf("Exported %d articles to %s\n", count, path)
+		return
+	}
+
+	archive, err := os.Create(path)
+	if err != nil {
+		panic(fmt.Errorf("Export articles: %s \n", err))
+	}
+	defer archive.Close()
+	if err := articleio.ExportZip(db, authorID, archive); err != nil {
+		panic(fmt.Errorf("Export articles: %s \n", err))
+	}
+	fmt.Printf("Exported articles to %s\n", path)
+}
+
+// seedDatabase fills the database with generated data. The same -seed
+// always generates the same users, articles and interactions. Outside dev it
+// refuses to run without -force.
+func seedDatabase(cfg *config.Config, db *models.DB, args []string) {
+	options := seed.DefaultOptions()
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	force := flags.Bool("force", false, "seed even though ENVIRONMENT isn't dev")
+	flags.Int64Var(&options.Seed, "seed", options.Seed, "random seed")
+	flags.IntVar(&options.Users, "users", options.Users, "number of users")
+	flags.IntVar(&options.ArticlesPerUser, "articles", options.ArticlesPerUser, "articles per user")
+	flags.IntVar(&options.FollowsPerUser, "follows", options.FollowsPerUser, "follows per user")
+	flags.IntVar(&options.FavoritesPerUser, "favorites", options.FavoritesPerUser, "favorites per user")
+	flags.IntVar(&options.CommentsPerArticle, "comments", options.CommentsPerArticle, "comments per article")
+	flags.StringVar(&options.Password, "password", options.Password, "password of the generated users")
+	flags.Parse(args)
+
+	if !cfg.IsDev() && !*force {
+		fmt.Fprintf(os.Stderr, "Refusing to seed a %s database; set ENVIRONMENT=dev or pass -force\n", cfg.Environment)
+		os.Exit(1)
+	}
+
+	summary, err := seed.Run(db, options)
+	if err != nil {
+		panic(fmt.Errorf("Seed: %s \n", err))
+	}
+	fmt.Printf("Created %d users, %d follows, %d articles, %d comments and %d favorites\n",
+		summary.Users, summary.Follows, summary.Articles, summary.Comments, summary.Favorites)
+}
@@ -0,0 +1,256 @@
+// Package config loads the settings of every command once at startup.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// devJWTKey signs tokens in development when JWT_SIGNED_KEY is unset.
+const devJWTKey = "THIS_IS_DEVELOPMENT_KEY"
+
+// minJWTKeyLength is the shortest JWT_SIGNED_KEY accepted outside dev: 32
+// bytes, the output size of HS256.
+const minJWTKeyLength = 32
+
+type Config struct {
+	// Environment is "dev" or anything else, which is treated as production.
+	Environment  string
+	PostgresURL  string
+	Port         string
+	JWTSignedKey string
+	// SiteURL is the public origin used in feed links; empty means the
+	// request's own host.
+	SiteURL string
+
+	Tracing         Tracing
+	Storage         Storage
+	RealtimeBackend string
+
+	ContentFilter     string
+	ContentFilterFile string
+
+	AccountDeletionPolicy string
+	ReportHideThreshold   uint
+	TrashRetention        time.Duration
+}
+
+type Tracing struct {
+	Exporter     string
+	ServiceName  string
+	OTLPEndpoint string
+	OTLPInsecure bool
+}
+
+type Storage struct {
+	Backend     string
+	MediaRoot   string
+	MediaURL    string
+	S3Endpoint  string
+	S3AccessKey string
+	S3SecretKey string
+	S3Bucket    string
+	S3UseSSL    bool
+}
+
+// setting is one configuration key, settable as KEY in the config file and
+// the environment and as -key-name on the command line.
+type setting struct {
+	Key     string
+	Default interface{}
+	Usage   string
+}
+
+var settings = []setting{
+	{"ENVIRONMENT", "production", `"dev" relaxes validation and reads config.toml by default`},
+	{"POSTGRES_URL", "", "Postgres connection string"},
+	{"GO_PORT", ":8080", "address the server listens on"},
+	{"JWT_SIGNED_KEY", "", "HS256 key signing the JWTs, at least 32 bytes outside dev"},
+	{"SITE_URL", "", "public origin used in feed links"},
+	{"OTEL_EXPORTER", "none", `"otlp", "stdout" or "none"`},
+	{"OTEL_SERVICE_NAME", "conduit", "service name reported in traces"},
+	{"OTEL_EXPORTER_OTLP_ENDPOINT", "localhost:4317", "OTLP collector address"},
+	{"OTEL_EXPORTER_OTLP_INSECURE", false, "talk to the collector without TLS"},
+	{"BLOB_STORE", "local", `"local" or "s3"`},
+	{"MEDIA_ROOT", "./media", "directory of the local blob store"},
+	{"MEDIA_URL", "/media/", "URL prefix of uploaded files"},
+	{"S3_ENDPOINT", "", "S3 endpoint"},
+	{"S3_ACCESS_KEY", "", "S3 access key"},
+	{"S3_SECRET_KEY", "", "S3 secret key"},
+	{"S3_BUCKET", "", "S3 bucket"},
+	{"S3_USE_SSL", false, "use TLS to reach S3"},
+	{"REALTIME_BACKEND", "memory", `"memory" or "postgres"`},
+	{"CONTENT_FILTER", "keywords", `"keywords" or "none"`},
+	{"CONTENT_FILTER_FILE", "", "blocklist replacing the built-in one"},
+	{"ACCOUNT_DELETION_POLICY", "anonymize", `"anonymize" or "delete"`},
+	{"REPORT_HIDE_THRESHOLD", 3, "open reports hiding an article or comment"},
+	{"TRASH_RETENTION", "720h", "how long deleted content stays restorable"},
+}
+
+// flagName turns POSTGRES_URL into postgres-url.
+func flagName(key string) string {
+	return strings.ToLower(strings.Replace(key, "_", "-", -1))
+}
+
+// NewFlagSet declares -config and a flag for every setting. Flags are kept
+// as strings so that Load can tell which ones were given.
+func NewFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.String("config", "", "config file (TOML, YAML or JSON); defaults to config.toml in dev")
+	for _, s := range settings {
+		flags.String(flagName(s.Key), "", fmt.Sprintf("%s (%s, default %v)", s.Usage, s.Key, s.Default))
+	}
+	return flags
+}
+
+// Load builds the configuration from flags, parsed from a NewFlagSet, and
+// validates it. A setting given as a flag beats the environment, which beats
+// the config file, which beats the default.
+//
+// The config file is the -config flag or CONFIG_FILE; in dev it defaults to
+// ./config.toml when that exists.
+func Load(flags *flag.FlagSet) (*Config, error) {
+	v := viper.New()
+	for _, s := range settings {
+		v.SetDefault(s.Key, s.Default)
+	}
+	v.AutomaticEnv()
+
+	given := map[string]string{}
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+	for _, s := range settings {
+		if value, ok := given[flagName(s.Key)]; ok {
+			v.Set(s.Key, value)
+		}
+	}
+
+	path, ok := given["config"]
+	if !ok {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" && isDev(v.GetString("ENVIRONMENT")) {
+		if _, err := os.Stat("config.toml"); err == nil {
+			path = "config.toml"
+		}
+	}
+	if path != "" {
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("Reading %s: %s", path, err)
+		}
+	}
+
+	config, err := fromViper(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func fromViper(v *viper.Viper) (*Config, error) {
+	threshold, err := cast.ToUintE(v.Get("REPORT_HIDE_THRESHOLD"))
+	if err != nil {
+		return nil, fmt.Errorf("REPORT_HIDE_THRESHOLD: %s", err)
+	}
+	retention, err := cast.ToDurationE(v.Get("TRASH_RETENTION"))
+	if err != nil {
+		return nil, fmt.Errorf("TRASH_RETENTION: %s", err)
+	}
+
+	config := &Config{
+		Environment:  strings.ToLower(v.GetString("ENVIRONMENT")),
+		PostgresURL:  v.GetString("POSTGRES_URL"),
+		Port:         v.GetString("GO_PORT"),
+		JWTSignedKey: v.GetString("JWT_SIGNED_KEY"),
+		SiteURL:      strings.TrimSuffix(v.GetString("SITE_URL"), "/"),
+		Tracing: Tracing{
+			Exporter:     v.GetString("OTEL_EXPORTER"),
+			ServiceName:  v.GetString("OTEL_SERVICE_NAME"),
+			OTLPEndpoint: v.GetString("OTEL_EXPORTER_OTLP_ENDPOINT"),
+			OTLPInsecure: v.GetBool("OTEL_EXPORTER_OTLP_INSECURE"),
+		},
+		Storage: Storage{
+			Backend:     v.GetString("BLOB_STORE"),
+			MediaRoot:   v.GetString("MEDIA_ROOT"),
+			MediaURL:    v.GetString("MEDIA_URL"),
+			S3Endpoint:  v.GetString("S3_ENDPOINT"),
+			S3AccessKey: v.GetString("S3_ACCESS_KEY"),
+			S3SecretKey: v.GetString("S3_SECRET_KEY"),
+			S3Bucket:    v.GetString("S3_BUCKET"),
+			S3UseSSL:    v.GetBool("S3_USE_SSL"),
+		},
+		RealtimeBackend:       v.GetString("REALTIME_BACKEND"),
+		ContentFilter:         v.GetString("CONTENT_FILTER"),
+		ContentFilterFile:     v.GetString("CONTENT_FILTER_FILE"),
+		AccountDeletionPolicy: v.GetString("ACCOUNT_DELETION_POLICY"),
+		ReportHideThreshold:   threshold,
+		TrashRetention:        retention,
+	}
+	if config.JWTSignedKey == "" && config.IsDev() {
+		config.JWTSignedKey = devJWTKey
+	}
+	return config, nil
+}
+
+func isDev(environment string) bool {
+	return strings.EqualFold(environment, "dev")
+}
+
+func (c *Config) IsDev() bool {
+	return isDev(c.Environment)
+}
+
+// Validate reports every invalid setting at once. Weak JWT keys are only
+// accepted in dev.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		check(false, "%s must be one of %s, not %q", key, strings.Join(allowed, ", "), value)
+	}
+
+	check(c.PostgresURL != "", "POSTGRES_URL is required")
+	check(c.Port != "", "GO_PORT is required")
+	check(c.JWTSignedKey != "", "JWT_SIGNED_KEY is required")
+	if !c.IsDev() && c.JWTSignedKey != "" {
+		check(len(c.JWTSignedKey) >= minJWTKeyLength && c.JWTSignedKey != devJWTKey,
+			"JWT_SIGNED_KEY must be a random key of at least %d bytes outside dev", minJWTKeyLength)
+	}
+
+	oneOf("OTEL_EXPORTER", c.Tracing.Exporter, "none", "stdout", "otlp")
+	oneOf("BLOB_STORE", c.Storage.Backend, "local", "s3")
+	if c.Storage.Backend == "s3" {
+		check(c.Storage.S3Endpoint != "" && c.Storage.S3Bucket != "", "S3_ENDPOINT and S3_BUCKET are required with BLOB_STORE=s3")
+	}
+	oneOf("REALTIME_BACKEND", c.RealtimeBackend, "memory", "postgres")
+	oneOf("CONTENT_FILTER", c.ContentFilter, "keywords", "none")
+	oneOf("ACCOUNT_DELETION_POLICY", c.AccountDeletionPolicy, "anonymize", "delete")
+	check(c.ReportHideThreshold > 0, "REPORT_HIDE_THRESHOLD must be positive")
+	check(c.TrashRetention > 0, "TRASH_RETENTION must be positive")
+
+	if len(problems) > 0 {
+		return errors.New("Invalid configuration:\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
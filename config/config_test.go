@@ -0,0 +1,45 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			Environment:           "production",
+			PostgresURL:           "postgres://localhost/conduit",
+			Port:                  ":8080",
+			JWTSignedKey:          strings.Repeat("k", minJWTKeyLength),
+			Tracing:               Tracing{Exporter: "none"},
+			Storage:               Storage{Backend: "local"},
+			RealtimeBackend:       "memory",
+			ContentFilter:         "keywords",
+			AccountDeletionPolicy: "anonymize",
+			ReportHideThreshold:   3,
+			TrashRetention:        720 * time.Hour,
+		}
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("valid config: %s", err)
+	}
+
+	tests := map[string]func(c *Config){
+		"POSTGRES_URL":            func(c *Config) { c.PostgresURL = "" },
+		"JWT_SIGNED_KEY":          func(c *Config) { c.JWTSignedKey = devJWTKey },
+		"BLOB_STORE":              func(c *Config) { c.Storage.Backend = "ftp" },
+		"S3_ENDPOINT":             func(c *Config) { c.Storage.Backend = "s3" },
+		"ACCOUNT_DELETION_POLICY": func(c *Config) { c.AccountDeletionPolicy = "keep" },
+		"REPORT_HIDE_THRESHOLD":   func(c *Config) { c.ReportHideThreshold = 0 },
+		"TRASH_RETENTION":         func(c *Config) { c.TrashRetention = 0 },
+	}
+	for key, change := range tests {
+		c := valid()
+		change(c)
+		if err := c.Validate(); err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("%s: Validate() = %v, want an error about it", key, err)
+		}
+	}
+}
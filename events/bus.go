@@ -0,0 +1,66 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Envelope is an event as handed to subscribers. Key is unique per event and
+// stays the same across redeliveries, so subscribers with side effects
+// outside the database can use it as an idempotency key.
+type Envelope struct {
+	Key        string
+	Name       string
+	Event      Event
+	OccurredAt time.Time
+
+	afterCommit []func()
+}
+
+// AfterCommit registers fn to run once the handler's transaction has
+// committed, for effects that must not happen if it rolls back, such as
+// pushing to connected clients.
+func (envelope *Envelope) AfterCommit(fn func()) {
+	envelope.afterCommit = append(envelope.afterCommit, fn)
+}
+
+// Handler applies the side effects of an event through tx, the transaction
+// that also records the event as processed by the subscriber, so that they
+// are committed exactly once. Returning an error rolls them back and the
+// event is retried.
+type Handler func(tx *gorm.DB, envelope *Envelope) error
+
+type subscriber struct {
+	name    string
+	handler Handler
+}
+
+// Bus routes events to the in-process subscribers of their name.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[string][]subscriber
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: map[string][]subscriber{},
+	}
+}
+
+// Subscribe registers handler for events named eventName. name identifies
+// the subscriber for de-duplication and must be stable across restarts.
+func (bus *Bus) Subscribe(name, eventName string, handler Handler) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	bus.subscribers[eventName] = append(bus.subscribers[eventName], subscriber{name, handler})
+}
+
+func (bus *Bus) subscribersOf(eventName string) []subscriber {
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+
+	return bus.subscribers[eventName]
+}
@@ -0,0 +1,150 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	dispatchMaxAttempts = 20
+	dispatchBaseBackoff = 5 * time.Second
+	dispatchMaxBackoff  = time.Hour
+)
+
+// Dispatcher delivers outbox events to the Bus at least once: an event is
+// only marked dispatched after every subscriber handled it, and subscribers
+// that already succeeded are skipped when a failed event is retried.
+type Dispatcher struct {
+	DB        *gorm.DB
+	Bus       *Bus
+	Interval  time.Duration
+	BatchSize int
+}
+
+func NewDispatcher(db *gorm.DB, bus *Bus) *Dispatcher {
+	return &Dispatcher{
+		DB:        db,
+		Bus:       bus,
+		Interval:  time.Second,
+		BatchSize: 50,
+	}
+}
+
+// Run polls the outbox until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil && d.DispatchBatch() > 0 {
+			// Keep draining while there is a backlog.
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchBatch delivers one batch of due events and returns its size.
+func (d *Dispatcher) DispatchBatch() int {
+	tx := d.DB.Begin()
+	defer tx.Rollback()
+
+	// SKIP LOCKED lets several instances share the outbox.
+	var outbox []*OutboxEvent
+	tx.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+		Where("dispatched_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", time.Now()).
+		Order("id").Limit(d.BatchSize).Find(&outbox)
+
+	for _, row := range outbox {
+		d.dispatch(tx, row)
+	}
+
+	tx.Commit()
+	return len(outbox)
+}
+
+func (d *Dispatcher) dispatch(tx *gorm.DB, row *OutboxEvent) {
+	event, err := decode(row.Name, row.Payload)
+	if err == nil {
+		envelope := &Envelope{
+			Key:        row.Key,
+			Name:       row.Name,
+			Event:      event,
+			OccurredAt: row.CreatedAt,
+		}
+		for _, sub := range d.Bus.subscribersOf(row.Name) {
+			if subErr := d.deliver(sub, envelope); subErr != nil {
+				err = fmt.Errorf("%s: %s", sub.name, subErr)
+			}
+		}
+	}
+
+	row.Attempts++
+	now := time.Now()
+	if err == nil {
+		tx.Model(row).Updates(map[string]interface{}{
+			"attempts":      row.Attempts,
+			"dispatched_at": now,
+			"last_error":    "",
+		})
+		return
+	}
+
+	updates := map[string]interface{}{
+		"attempts":   row.Attempts,
+		"last_error": err.Error(),
+	}
+	if row.Attempts >= dispatchMaxAttempts {
+		updates["failed_at"] = now
+	} else {
+		backoff := dispatchBaseBackoff << uint(row.Attempts-1)
+		if backoff > dispatchMaxBackoff || backoff <= 0 {
+			backoff = dispatchMaxBackoff
+		}
+		updates["next_attempt_at"] = now.Add(backoff)
+	}
+	tx.Model(row).Updates(updates)
+}
+
+// deliver runs one subscriber unless it already processed the event. The
+// handler and the processed_events row share a transaction, so a crash
+// between the two can neither lose nor repeat the side effects.
+func (d *Dispatcher) deliver(sub subscriber, envelope *Envelope) (err error) {
+	delivery := *envelope
+	delivery.afterCommit = nil
+
+	tx := d.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprint(r))
+		}
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		if err = tx.Commit().Error; err == nil {
+			for _, fn := range delivery.afterCommit {
+				fn()
+			}
+		}
+	}()
+
+	// Claiming the key first makes another dispatcher delivering the same
+	// event wait on the unique index until this transaction ends. Create
+	// would report the conflict as sql.ErrNoRows, so insert directly.
+	results := tx.Exec(`INSERT INTO processed_events (created_at, subscriber, key) VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING`, gorm.NowFunc(), sub.name, envelope.Key)
+	if results.Error != nil || results.RowsAffected == 0 {
+		return results.Error
+	}
+
+	return sub.handler(tx, &delivery)
+}
@@ -0,0 +1,152 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Event is a domain event emitted by a model operation.
+type Event interface {
+	EventName() string
+}
+
+type ArticleCreated struct {
+	ArticleID uint `json:"articleId"`
+	AuthorID  uint `json:"authorId"`
+}
+
+type ArticleUpdated struct {
+	ArticleID uint `json:"articleId"`
+	AuthorID  uint `json:"authorId"`
+}
+
+// ArticleDeleted carries the slug and title since the article can no longer
+// be loaded once deleted.
+type ArticleDeleted struct {
+	ArticleID uint   `json:"articleId"`
+	AuthorID  uint   `json:"authorId"`
+	Slug      string `json:"slug"`
+	Title     string `json:"title"`
+}
+
+type CommentCreated struct {
+	CommentID       uint `json:"commentId"`
+	ArticleID       uint `json:"articleId"`
+	AuthorID        uint `json:"authorId"`
+	ArticleAuthorID uint `json:"articleAuthorId"`
+}
+
+type ArticleFavorited struct {
+	ArticleID       uint `json:"articleId"`
+	UserID          uint `json:"userId"`
+	ArticleAuthorID uint `json:"articleAuthorId"`
+}
+
+type ArticleUnfavorited struct {
+	ArticleID       uint `json:"articleId"`
+	UserID          uint `json:"userId"`
+	ArticleAuthorID uint `json:"articleAuthorId"`
+}
+
+type UserFollowed struct {
+	FollowerID  uint `json:"followerId"`
+	FollowingID uint `json:"followingId"`
+}
+
+type UserUnfollowed struct {
+	FollowerID  uint `json:"followerId"`
+	FollowingID uint `json:"followingId"`
+}
+
+func (*ArticleCreated) EventName() string     { return "article.created" }
+func (*ArticleUpdated) EventName() string     { return "article.updated" }
+func (*ArticleDeleted) EventName() string     { return "article.deleted" }
+func (*CommentCreated) EventName() string     { return "comment.created" }
+func (*ArticleFavorited) EventName() string   { return "article.favorited" }
+func (*ArticleUnfavorited) EventName() string { return "article.unfavorited" }
+func (*UserFollowed) EventName() string       { return "user.followed" }
+func (*UserUnfollowed) EventName() string     { return "user.unfollowed" }
+
+// registry builds an empty event of each name for decoding the outbox.
+var registry = map[string]func() Event{}
+
+func register(factory func() Event) {
+	registry[factory().EventName()] = factory
+}
+
+func init() {
+	register(func() Event { return &ArticleCreated{} })
+	register(func() Event { return &ArticleUpdated{} })
+	register(func() Event { return &ArticleDeleted{} })
+	register(func() Event { return &CommentCreated{} })
+	register(func() Event { return &ArticleFavorited{} })
+	register(func() Event { return &ArticleUnfavorited{} })
+	register(func() Event { return &UserFollowed{} })
+	register(func() Event { return &UserUnfollowed{} })
+}
+
+func decode(name, payload string) (Event, error) {
+	factory, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown event: %s", name)
+	}
+
+	event := factory()
+	if err := json.Unmarshal([]byte(payload), event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// OutboxEvent is an event waiting to be dispatched. Rows are written in the
+// transaction of the change that caused them, so an event exists if and only
+// if its change was committed.
+type OutboxEvent struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+
+	Key           string `gorm:"unique_index"`
+	Name          string
+	Payload       string `gorm:"type:text"`
+	Attempts      int
+	NextAttemptAt time.Time  `gorm:"index"`
+	LastError     string     `gorm:"type:text"`
+	DispatchedAt  *time.Time `gorm:"index"`
+	FailedAt      *time.Time
+}
+
+// ProcessedEvent remembers which subscriber already handled which event so a
+// redelivered event isn't handled twice.
+type ProcessedEvent struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+
+	Subscriber string `gorm:"unique_index:processed_event"`
+	Key        string `gorm:"unique_index:processed_event"`
+}
+
+// Record writes event to the outbox using tx, which should be the
+// transaction making the change the event describes.
+func Record(tx *gorm.DB, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+
+	return tx.Create(&OutboxEvent{
+		Key:           hex.EncodeToString(buf),
+		Name:          event.EventName(),
+		Payload:       string(payload),
+		NextAttemptAt: time.Now(),
+	}).Error
+}
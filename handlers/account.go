@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+)
+
+// UserExportHandler sends everything stored about the logged in user as a
+// ZIP of JSON files, or as a single JSON document with ?format=json.
+func (app *App) UserExportHandler(w http.ResponseWriter, r *http.Request) {
+	db := app.DB.WithContext(r.Context())
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	export := db.ExportUser(uint(loggedInUserID.(float64)))
+	filename := fmt.Sprintf("conduit-%s-%s", export.Profile.Username, time.Now().UTC().Format("20060102"))
+
+	if r.URL.Query().Get("format") == "json" {
+		resp, err := json.Marshal(export)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write(JsonErrorResponse("_", err.Error()))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		w.Write(resp)
+		return
+	}
+
+	files := []struct {
+		Name string
+		Data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"articles.json", export.Articles},
+		{"comments.json", export.Comments},
+		{"favorites.json", export.Favorites},
+		{"bookmarks.json", export.Bookmarks},
+		{"following.json", export.Following},
+		{"followers.json", export.Followers},
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, filename))
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		f, err := archive.Create(file.Name)
+		if err != nil {
+			return
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.Data); err != nil {
+			return
+		}
+	}
+	archive.Close()
+}
+
+type DeleteUser struct {
+	User struct {
+		Password string `json:"password" validate:"required"`
+	} `json:"user"`
+}
+
+// UserDeleteHandler revokes the logged in user's tokens and queues the
+// deletion of their account once they confirm it with their password. The
+// response carries a token for polling AccountDeletionHandler.
+func (app *App) UserDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	body := DeleteUser{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	err = app.Validator.Struct(body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponseFromValidator(err))
+		return
+	}
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	user := db.GetUserFromID(uint(loggedInUserID.(float64)))
+	if user.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return
+	}
+
+	if !user.CheckPassword(body.User.Password) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("password", "is invalid"))
+		return
+	}
+
+	deletion := db.RequestAccountDeletion(user, app.Config.AccountDeletionPolicy)
+	resp, err := json.Marshal(deletion)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Header().Set("Location", "/api/account-deletions/"+deletion.Deletion.Token)
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(resp)
+}
+
+func (app *App) AccountDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	vars := mux.Vars(r)
+	deletion := db.GetAccountDeletion(vars["token"])
+	if deletion.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return
+	}
+
+	resp, err := json.Marshal(db.PrepareAccountDeletionResponse(deletion))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
@@ -1,11 +1,22 @@
 package handlers
 
 import (
+	"github.com/koyoyo/realworld-starter-kit/config"
 	"github.com/koyoyo/realworld-starter-kit/models"
+	"github.com/koyoyo/realworld-starter-kit/moderation"
+	"github.com/koyoyo/realworld-starter-kit/realtime"
+	"github.com/koyoyo/realworld-starter-kit/storage"
 	"gopkg.in/go-playground/validator.v9"
 )
 
 type App struct {
+	Config    *config.Config
 	DB        models.DB
 	Validator *validator.Validate
+	Store     storage.BlobStore
+	Hub       realtime.Hub
+	Filter    moderation.ContentFilter
+	Version   string
+
+	shuttingDown int32
 }
@@ -8,6 +8,7 @@ import (
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/mux"
 	"github.com/koyoyo/realworld-starter-kit/models"
+	"github.com/koyoyo/realworld-starter-kit/realtime"
 )
 
 type ArticleForm struct {
@@ -19,6 +20,12 @@ type ArticleForm struct {
 	} `json:"article"`
 }
 
+type TagForm struct {
+	Tag struct {
+		Description string `json:"description"`
+	} `json:"tag"`
+}
+
 type CommentForm struct {
 	Comment struct {
 		Body string `json:"body" validate:"required"`
@@ -27,6 +34,7 @@ type CommentForm struct {
 
 func (app *App) ArticleCreateHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 	r.ParseForm()
 
 	body := ArticleForm{}
@@ -49,8 +57,24 @@ func (app *App) ArticleCreateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	article := app.DB.CreateArticle(body.Article.Title, body.Article.Description, body.Article.Body,
+	if err := app.Filter.Check(body.Article.Title, body.Article.Description, body.Article.Body); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("article", err.Error()))
+		return
+	}
+
+	article, err := db.CreateArticle(body.Article.Title, body.Article.Description, body.Article.Body,
 		body.Article.TagList, uint(loggedInUserID.(float64)))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+	ref := realtime.ArticleRef{Slug: article.Article.Slug, Author: article.Article.Author.Username}
+	app.publish(realtime.AuthorTopic(uint(loggedInUserID.(float64))), "article.created", ref)
+	for _, tag := range article.Article.Tag {
+		app.publish(realtime.TagTopic(tag), "article.created", ref)
+	}
 	resp, err := json.Marshal(&article)
 	if err != nil {
 		w.WriteHeader(http.StatusUnprocessableEntity)
@@ -63,17 +87,18 @@ func (app *App) ArticleCreateHandler(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) ArticleListHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 
 	var articles *models.ArticlesResponseJson
 
 	if userToken := r.Context().Value("user"); userToken != nil {
 		if loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]; loggedInUserID != nil {
-			articles = app.DB.ListArticleWithUser(r.URL.Query(), uint(loggedInUserID.(float64)))
+			articles = db.ListArticleWithUser(r.URL.Query(), uint(loggedInUserID.(float64)))
 		} else {
-			articles = app.DB.ListArticle(r.URL.Query())
+			articles = db.ListArticle(r.URL.Query())
 		}
 	} else {
-		articles = app.DB.ListArticle(r.URL.Query())
+		articles = db.ListArticle(r.URL.Query())
 	}
 
 	resp, err := json.Marshal(&articles)
@@ -88,6 +113,7 @@ func (app *App) ArticleListHandler(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) ArticleFeedHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 
 	userToken := r.Context().Value("user")
 	if userToken == nil {
@@ -101,7 +127,7 @@ func (app *App) ArticleFeedHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	articles := app.DB.ListArticleFeed(r.URL.Query(), uint(loggedInUserID.(float64)))
+	articles := db.ListArticleFeed(r.URL.Query(), uint(loggedInUserID.(float64)))
 
 	resp, err := json.Marshal(&articles)
 	if err != nil {
@@ -115,10 +141,11 @@ func (app *App) ArticleFeedHandler(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) ArticleDetailHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 
 	vars := mux.Vars(r)
 	slug := vars["slug"]
-	article := app.DB.GetArticleResponseFromSlug(slug)
+	article := db.GetArticleResponseFromSlug(slug)
 	if article.Article.Slug == "" {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write(JsonErrorNotFoundResponse())
@@ -129,8 +156,9 @@ func (app *App) ArticleDetailHandler(w http.ResponseWriter, r *http.Request) {
 	if userToken != nil {
 		loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
 		if loggedInUserID != nil {
-			article.Article.Favorited = app.DB.IsFavorite(article.Article.ID, uint(loggedInUserID.(float64)))
-			article.Article.Author.Following = app.DB.IsFollowing(article.Article.Author.ID, uint(loggedInUserID.(float64)))
+			article.Article.Favorited = db.IsFavorite(article.Article.ID, uint(loggedInUserID.(float64)))
+			article.Article.Bookmarked = db.IsBookmarked(article.Article.ID, uint(loggedInUserID.(float64)))
+			article.Article.Author.Following = db.IsFollowing(uint(loggedInUserID.(float64)), article.Article.Author.ID)
 		}
 	}
 
@@ -147,6 +175,7 @@ func (app *App) ArticleDetailHandler(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) ArticleUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 	r.ParseForm()
 
 	body := ArticleForm{}
@@ -159,7 +188,7 @@ func (app *App) ArticleUpdateHandler(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	slug := vars["slug"]
-	article := app.DB.GetArticleFromSlug(slug)
+	article := db.GetArticleFromSlug(slug)
 	if article.Slug == "" {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write(JsonErrorNotFoundResponse())
@@ -183,7 +212,19 @@ func (app *App) ArticleUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	articleResponse := app.DB.UpdateArticle(article, body.Article.Title, body.Article.Description, body.Article.Body)
+	if err := app.Filter.Check(body.Article.Title, body.Article.Description, body.Article.Body); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("article", err.Error()))
+		return
+	}
+
+	articleResponse, err := db.UpdateArticle(article, body.Article.Title, body.Article.Description, body.Article.Body,
+		body.Article.TagList)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
 	resp, err := json.Marshal(&articleResponse)
 	if err != nil {
 		w.WriteHeader(http.StatusUnprocessableEntity)
@@ -196,10 +237,11 @@ func (app *App) ArticleUpdateHandler(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) ArticleDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 
 	vars := mux.Vars(r)
 	slug := vars["slug"]
-	article := app.DB.GetArticleFromSlug(slug)
+	article := db.GetArticleFromSlug(slug)
 	if article.Slug == "" {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write(JsonErrorNotFoundResponse())
@@ -223,17 +265,27 @@ func (app *App) ArticleDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	app.DB.DeleteArticle(article)
+	if err := db.DeleteArticle(article); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
 	return
 }
 
 func (app *App) ArticleFavoriteHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 
 	vars := mux.Vars(r)
 	slug := vars["slug"]
-	article := app.DB.GetArticleResponseFromSlug(slug)
+	article := db.GetArticleResponseFromSlug(slug)
+	if article.Article.Slug == "" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return
+	}
 
 	userToken := r.Context().Value("user")
 	if userToken == nil {
@@ -247,10 +299,18 @@ func (app *App) ArticleFavoriteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	isAlreadyFav := app.DB.FavoriteArticle(article.Article.ID, uint(loggedInUserID.(float64)))
-	if !isAlreadyFav {
-		article.Article.FavoritesCount++
+	if db.IsBlocked(article.Article.Author.ID, uint(loggedInUserID.(float64))) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	favoritesCount, err := db.FavoriteArticle(article.Article.ID, uint(loggedInUserID.(float64)))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
 	}
+	article.Article.FavoritesCount = favoritesCount
 
 	article.Article.Favorited = true
 	resp, err := json.Marshal(&article)
@@ -265,10 +325,16 @@ func (app *App) ArticleFavoriteHandler(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) ArticleUnfavoriteHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 
 	vars := mux.Vars(r)
 	slug := vars["slug"]
-	article := app.DB.GetArticleResponseFromSlug(slug)
+	article := db.GetArticleResponseFromSlug(slug)
+	if article.Article.Slug == "" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return
+	}
 
 	userToken := r.Context().Value("user")
 	if userToken == nil {
@@ -282,10 +348,13 @@ func (app *App) ArticleUnfavoriteHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	isAlreadyUnfav := app.DB.UnfavoriteArticle(article.Article.ID, uint(loggedInUserID.(float64)))
-	if !isAlreadyUnfav {
-		article.Article.FavoritesCount--
+	favoritesCount, err := db.UnfavoriteArticle(article.Article.ID, uint(loggedInUserID.(float64)))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
 	}
+	article.Article.FavoritesCount = favoritesCount
 
 	article.Article.Favorited = false
 	resp, err := json.Marshal(&article)
@@ -300,6 +369,7 @@ func (app *App) ArticleUnfavoriteHandler(w http.ResponseWriter, r *http.Request)
 
 func (app *App) ArticleCommentAddHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 	r.ParseForm()
 
 	body := CommentForm{}
@@ -312,7 +382,7 @@ func (app *App) ArticleCommentAddHandler(w http.ResponseWriter, r *http.Request)
 
 	vars := mux.Vars(r)
 	slug := vars["slug"]
-	article := app.DB.GetArticleFromSlug(slug)
+	article := db.GetArticleFromSlug(slug)
 	if article.Slug == "" {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write(JsonErrorNotFoundResponse())
@@ -331,7 +401,25 @@ func (app *App) ArticleCommentAddHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	comment := app.DB.AddArticleComment(article, uint(loggedInUserID.(float64)), body.Comment.Body)
+	if db.IsBlocked(article.AuthorID, uint(loggedInUserID.(float64))) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if err := app.Filter.Check(body.Comment.Body); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("comment", err.Error()))
+		return
+	}
+
+	comment, err := db.AddArticleComment(article, uint(loggedInUserID.(float64)), body.Comment.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+	app.publish(realtime.ArticleTopic(article.Slug), "comment.created",
+		realtime.CommentRef{ID: comment.Comment.ID, Article: article.Slug})
 	resp, err := json.Marshal(&comment)
 	if err != nil {
 		w.WriteHeader(http.StatusUnprocessableEntity)
@@ -343,11 +431,12 @@ func (app *App) ArticleCommentAddHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (app *App) ArticleCommentListHandler(w http.ResponseWriter, r *http.Request) {
+	db := app.DB.WithContext(r.Context())
 	var comments *models.CommentsResponseJson
 
 	vars := mux.Vars(r)
 	slug := vars["slug"]
-	article := app.DB.GetArticleFromSlug(slug)
+	article := db.GetArticleFromSlug(slug)
 	if article.Slug == "" {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write(JsonErrorNotFoundResponse())
@@ -356,12 +445,12 @@ func (app *App) ArticleCommentListHandler(w http.ResponseWriter, r *http.Request
 
 	if userToken := r.Context().Value("user"); userToken != nil {
 		if loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]; loggedInUserID != nil {
-			comments = app.DB.ListArticleCommentWithUser(article.ID, uint(loggedInUserID.(float64)))
+			comments = db.ListArticleCommentWithUser(article.ID, uint(loggedInUserID.(float64)))
 		} else {
-			comments = app.DB.ListArticleComment(article.ID)
+			comments = db.ListArticleComment(article.ID)
 		}
 	} else {
-		comments = app.DB.ListArticleComment(article.ID)
+		comments = db.ListArticleComment(article.ID)
 	}
 
 	resp, err := json.Marshal(&comments)
@@ -376,6 +465,7 @@ func (app *App) ArticleCommentListHandler(w http.ResponseWriter, r *http.Request
 
 func (app *App) ArticleCommentDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 
 	vars := mux.Vars(r)
 	slug := vars["slug"]
@@ -386,7 +476,7 @@ func (app *App) ArticleCommentDeleteHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	comment := app.DB.GetArticleComment(uint(commentID), slug)
+	comment := db.GetArticleComment(uint(commentID), slug)
 	if comment.ID == 0 {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write(JsonErrorNotFoundResponse())
@@ -410,15 +500,24 @@ func (app *App) ArticleCommentDeleteHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	app.DB.DeleteArticleComment(comment)
+	db.DeleteArticleComment(comment)
 	w.WriteHeader(http.StatusNoContent)
 	return
 }
 
 func (app *App) TagsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 
-	tags := app.DB.ListTags()
+	var tags *models.TagResponse
+	if userToken := r.Context().Value("user"); userToken != nil {
+		if loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]; loggedInUserID != nil {
+			tags = db.ListTagsWithUser(r.URL.Query(), uint(loggedInUserID.(float64)))
+		}
+	}
+	if tags == nil {
+		tags = db.ListTags(r.URL.Query())
+	}
 	resp, err := json.Marshal(&tags)
 	if err != nil {
 		w.WriteHeader(http.StatusUnprocessableEntity)
@@ -428,3 +527,130 @@ func (app *App) TagsHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Write(resp)
 }
+
+func (app *App) TagDetailHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	vars := mux.Vars(r)
+	tag := db.GetTag(vars["tag"])
+	if tag.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return
+	}
+
+	tagResponse := db.PrepareTagResponse(tag)
+	if userToken := r.Context().Value("user"); userToken != nil {
+		if loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]; loggedInUserID != nil {
+			tagResponse.Tag.Following = db.IsFollowingTag(tag.ID, uint(loggedInUserID.(float64)))
+		}
+	}
+
+	resp, err := json.Marshal(&tagResponse)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) TagUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	body := TagForm{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	vars := mux.Vars(r)
+	tag := db.GetTag(vars["tag"])
+	if tag.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return
+	}
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if !db.IsAdmin(uint(loggedInUserID.(float64))) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	resp, err := json.Marshal(db.UpdateTag(tag, body.Tag.Description))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) TagFollowHandler(w http.ResponseWriter, r *http.Request) {
+	app.followTag(w, r, true)
+}
+
+func (app *App) TagUnfollowHandler(w http.ResponseWriter, r *http.Request) {
+	app.followTag(w, r, false)
+}
+
+func (app *App) followTag(w http.ResponseWriter, r *http.Request, following bool) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	vars := mux.Vars(r)
+	tag := db.GetTag(vars["tag"])
+	if tag.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return
+	}
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if following {
+		db.FollowTag(tag.ID, uint(loggedInUserID.(float64)))
+	} else {
+		db.UnfollowTag(tag.ID, uint(loggedInUserID.(float64)))
+	}
+	app.publish(realtime.UserTopic(uint(loggedInUserID.(float64))), realtime.SubscriptionsChanged, nil)
+
+	tagResponse := db.PrepareTagResponse(tag)
+	tagResponse.Tag.Following = following
+	resp, err := json.Marshal(&tagResponse)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
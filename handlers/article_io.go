@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/koyoyo/realworld-starter-kit/articleio"
+)
+
+const importMaxSize = 32 << 20
+
+type ImportResponseJson struct {
+	Results []*articleio.Result `json:"results"`
+}
+
+// ArticleImportHandler imports the uploaded "file", either a single Markdown
+// document or a ZIP of them, as articles of the logged in user.
+func (app *App) ArticleImportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxSize+1<<20)
+	if err := r.ParseMultipartForm(importMaxSize); err != nil {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write(JsonErrorResponse("file", err.Error()))
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("file", "required"))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, importMaxSize+1))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("file", err.Error()))
+		return
+	}
+	if int64(len(data)) > importMaxSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write(JsonErrorResponse("file", fmt.Sprintf("must be at most %d bytes", importMaxSize)))
+		return
+	}
+
+	var files []articleio.File
+	if http.DetectContentType(data) == "application/zip" {
+		files, err = articleio.ReadZip(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write(JsonErrorResponse("file", err.Error()))
+			return
+		}
+	} else {
+		files = []articleio.File{{Name: header.Filename, Data: data}}
+	}
+
+	results := articleio.Import(db, uint(loggedInUserID.(float64)), files, articleio.Options{
+		Check: app.Filter.Check,
+	})
+	resp, err := json.Marshal(&ImportResponseJson{Results: results})
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+// ArticleExportHandler sends the logged in user's articles as a ZIP of
+// Markdown files with front matter.
+func (app *App) ArticleExportHandler(w http.ResponseWriter, r *http.Request) {
+	db := app.DB.WithContext(r.Context())
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="articles-%s.zip"`, time.Now().UTC().Format("20060102")))
+	articleio.ExportZip(db, uint(loggedInUserID.(float64)), w)
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/feeds"
+	"github.com/gorilla/mux"
+
+	"github.com/koyoyo/realworld-starter-kit/markdown"
+	"github.com/koyoyo/realworld-starter-kit/models"
+)
+
+type feedFormat int
+
+const (
+	atomFormat feedFormat = iota
+	rssFormat
+)
+
+func (app *App) GlobalAtomHandler(w http.ResponseWriter, r *http.Request) {
+	db := app.DB.WithContext(r.Context())
+
+	articles := db.SyndicationArticles(r.URL.Query())
+	app.writeFeed(w, r, atomFormat, "Conduit", "/", articles)
+}
+
+func (app *App) TagRSSHandler(w http.ResponseWriter, r *http.Request) {
+	db := app.DB.WithContext(r.Context())
+
+	vars := mux.Vars(r)
+	tag := vars["tag"]
+	articles := db.SyndicationArticles(url.Values{"tag": []string{tag}})
+	app.writeFeed(w, r, rssFormat, "Conduit: #"+tag, "/?tag="+url.QueryEscape(tag), articles)
+}
+
+func (app *App) AuthorAtomHandler(w http.ResponseWriter, r *http.Request) {
+	db := app.DB.WithContext(r.Context())
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+	profile := db.GetUserProfile(username)
+	if profile.Profile.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	articles := db.SyndicationArticles(url.Values{"author": []string{username}})
+	app.writeFeed(w, r, atomFormat, "Conduit: "+username, "/profile/"+url.PathEscape(username), articles)
+}
+
+// PrivateAtomHandler serves a user's personal feed. Feed readers can't send
+// a JWT, so the user is identified by the secret token in the URL instead.
+func (app *App) PrivateAtomHandler(w http.ResponseWriter, r *http.Request) {
+	db := app.DB.WithContext(r.Context())
+
+	vars := mux.Vars(r)
+	user := db.GetUserFromFeedToken(vars["token"])
+	if user.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	articles := db.SyndicationArticleFeed(url.Values{}, user.ID)
+	// The URL is the credential, so shared caches must not keep a copy.
+	w.Header().Set("Cache-Control", "private")
+	app.writeFeed(w, r, atomFormat, "Conduit: "+user.Username+"'s feed", "/", articles)
+}
+
+func (app *App) GetFeedTokenHandler(w http.ResponseWriter, r *http.Request) {
+	app.feedToken(w, r, false)
+}
+
+func (app *App) ResetFeedTokenHandler(w http.ResponseWriter, r *http.Request) {
+	app.feedToken(w, r, true)
+}
+
+func (app *App) feedToken(w http.ResponseWriter, r *http.Request, reset bool) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	userToken := r.Context().Value("user")
+	username := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["Username"]
+	if username == nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", "Can't extract user token."))
+		return
+	}
+
+	user := db.GetUserFromUsername(username.(string))
+	var token string
+	if reset {
+		token = db.ResetFeedToken(&user.User)
+	} else {
+		token = db.GetFeedToken(&user.User)
+	}
+
+	resp, err := json.Marshal(map[string]string{
+		"feedUrl": app.siteURL(r) + "/feeds/users/" + token + ".atom",
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) siteURL(r *http.Request) string {
+	if app.Config.SiteURL != "" {
+		return app.Config.SiteURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// writeFeed renders articles as Atom or RSS, answering conditional requests
+// from the newest UpdatedAt of the listed articles.
+func (app *App) writeFeed(w http.ResponseWriter, r *http.Request, format feedFormat, title, path string,
+	articles []*models.Article) {
+	var lastModified time.Time
+	hash := sha1.New()
+	for _, article := range articles {
+		if article.UpdatedAt.After(lastModified) {
+			lastModified = article.UpdatedAt
+		}
+		fmt.Fprintf(hash, "%d:%d;", article.ID, article.UpdatedAt.UnixNano())
+	}
+	etag := fmt.Sprintf(`"%x"`, hash.Sum(nil))
+
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if etagMatches(match, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !lastModified.IsZero() {
+		if !lastModified.Truncate(time.Second).After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	site := app.siteURL(r)
+	feed := &feeds.Feed{
+		Title:   title,
+		Link:    &feeds.Link{Href: site + path},
+		Id:      site + r.URL.Path,
+		Updated: lastModified,
+	}
+	for _, article := range articles {
+		content := article.BodyHTML
+		if content == "" {
+			content, _ = markdown.Render(article.Body)
+		}
+
+		feed.Items = append(feed.Items, &feeds.Item{
+			Id:          site + "/article/" + article.Slug,
+			Title:       article.Title,
+			Link:        &feeds.Link{Href: site + "/article/" + article.Slug},
+			Description: article.Description,
+			Content:     content,
+			Author:      &feeds.Author{Name: article.Author.Username},
+			Created:     article.CreatedAt,
+			Updated:     article.UpdatedAt,
+		})
+	}
+
+	var body string
+	var err error
+	if format == rssFormat {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		body, err = feed.ToRss()
+	} else {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		body, err = feed.ToAtom()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte(body))
+}
+
+// etagMatches reports whether the If-None-Match header lists etag or is "*".
+// As RFC 7232 asks of If-None-Match, the comparison is weak: a W/ prefix on
+// either side is ignored.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/koyoyo/realworld-starter-kit/config"
+	"github.com/koyoyo/realworld-starter-kit/models"
+)
+
+func TestWriteFeedConditional(t *testing.T) {
+	app := &App{Config: &config.Config{SiteURL: "https://conduit.example"}}
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+	articles := []*models.Article{{
+		ID:        1,
+		Slug:      "hello",
+		Title:     "Hello",
+		BodyHTML:  "<p>hi</p>",
+		CreatedAt: updated,
+		UpdatedAt: updated,
+		Author:    models.User{Username: "jake"},
+	}}
+
+	serve := func(header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/feeds/articles.atom", nil)
+		r.Header = header
+		w := httptest.NewRecorder()
+		app.writeFeed(w, r, atomFormat, "Conduit", "/", articles)
+		return w
+	}
+
+	first := serve(http.Header{})
+	if first.Code != http.StatusOK || first.Body.Len() == 0 {
+		t.Fatalf("unconditional request: %d with %d bytes", first.Code, first.Body.Len())
+	}
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	if got, want := first.Header().Get("Last-Modified"), updated.Format(http.TimeFormat); got != want {
+		t.Errorf("Last-Modified = %q, want %q", got, want)
+	}
+
+	for name, test := range map[string]struct {
+		header http.Header
+		code   int
+	}{
+		"same etag":          {http.Header{"If-None-Match": {etag}}, http.StatusNotModified},
+		"weak etag":          {http.Header{"If-None-Match": {"W/" + etag}}, http.StatusNotModified},
+		"etag in a list":     {http.Header{"If-None-Match": {`"other", ` + etag}}, http.StatusNotModified},
+		"any etag":           {http.Header{"If-None-Match": {"*"}}, http.StatusNotModified},
+		"other etag":         {http.Header{"If-None-Match": {`"other"`}}, http.StatusOK},
+		"not modified since": {http.Header{"If-Modified-Since": {updated.Format(http.TimeFormat)}}, http.StatusNotModified},
+		"modified since": {
+			http.Header{"If-Modified-Since": {updated.Add(-time.Second).Format(http.TimeFormat)}},
+			http.StatusOK,
+		},
+		// If-None-Match takes precedence over If-Modified-Since.
+		"other etag, not modified since": {http.Header{
+			"If-None-Match":     {`"other"`},
+			"If-Modified-Since": {updated.Format(http.TimeFormat)},
+		}, http.StatusOK},
+	} {
+		if w := serve(test.header); w.Code != test.code {
+			t.Errorf("%s: status %d, want %d", name, w.Code, test.code)
+		}
+	}
+}
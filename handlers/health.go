@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const readyPingTimeout = 2 * time.Second
+
+type dependencyStatus struct {
+	Status     string   `json:"status"`
+	Error      string   `json:"error,omitempty"`
+	Tables     []string `json:"missingTables,omitempty"`
+	Migrations []string `json:"pendingMigrations,omitempty"`
+}
+
+type readyResponse struct {
+	Status       string                       `json:"status"`
+	Version      string                       `json:"version"`
+	Dependencies map[string]*dependencyStatus `json:"dependencies"`
+}
+
+// SetShuttingDown makes ReadyHandler report not-ready so the orchestrator
+// stops routing traffic while in-flight requests drain.
+func (app *App) SetShuttingDown() {
+	atomic.StoreInt32(&app.shuttingDown, 1)
+}
+
+func (app *App) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	resp, err := json.Marshal(map[string]string{
+		"status":  "ok",
+		"version": app.Version,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ready := readyResponse{
+		Status:       "ok",
+		Version:      app.Version,
+		Dependencies: map[string]*dependencyStatus{},
+	}
+
+	if atomic.LoadInt32(&app.shuttingDown) == 1 {
+		ready.Status = "shutting_down"
+		ready.Dependencies["server"] = &dependencyStatus{Status: "shutting_down"}
+	} else {
+		ready.Dependencies["server"] = &dependencyStatus{Status: "ok"}
+	}
+
+	database := &dependencyStatus{Status: "ok"}
+	if err := app.DB.Ping(readyPingTimeout); err != nil {
+		database.Status = "error"
+		database.Error = err.Error()
+		ready.Status = "error"
+	}
+	ready.Dependencies["postgres"] = database
+
+	schema := &dependencyStatus{Status: "ok"}
+	if database.Status == "ok" {
+		ctx, cancel := context.WithTimeout(r.Context(), readyPingTimeout)
+		missing, pending, err := app.DB.SchemaStatus(ctx)
+		cancel()
+		if err != nil {
+			schema.Status = "error"
+			schema.Error = err.Error()
+			ready.Status = "error"
+		} else if len(missing) > 0 || len(pending) > 0 {
+			schema.Status = "pending"
+			schema.Tables = missing
+			schema.Migrations = pending
+			ready.Status = "error"
+		}
+	} else {
+		schema.Status = "unknown"
+	}
+	ready.Dependencies["schema"] = schema
+
+	resp, err := json.Marshal(&ready)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	if ready.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(resp)
+}
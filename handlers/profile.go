@@ -3,17 +3,21 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/mux"
+	"github.com/koyoyo/realworld-starter-kit/models"
+	"github.com/koyoyo/realworld-starter-kit/realtime"
 )
 
 func (app *App) GetUserProfileHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 
 	vars := mux.Vars(r)
 	username := vars["username"]
-	profile := app.DB.GetUserProfile(username)
+	profile := db.GetUserProfile(username)
 	if profile.Profile.ID == 0 {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write(JsonErrorResponse("_", "User not found"))
@@ -24,7 +28,9 @@ func (app *App) GetUserProfileHandler(w http.ResponseWriter, r *http.Request) {
 	if userToken != nil {
 		loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
 		if loggedInUserID != nil {
-			profile.Profile.Following = app.DB.IsFollowing(profile.Profile.ID, uint(loggedInUserID.(float64)))
+			profile.Profile.Following = db.IsFollowing(uint(loggedInUserID.(float64)), profile.Profile.ID)
+			profile.Profile.Blocking = db.IsBlocked(uint(loggedInUserID.(float64)), profile.Profile.ID)
+			profile.Profile.Muting = db.IsMuted(uint(loggedInUserID.(float64)), profile.Profile.ID)
 		}
 	}
 
@@ -40,10 +46,16 @@ func (app *App) GetUserProfileHandler(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) FollowHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 
 	vars := mux.Vars(r)
 	username := vars["username"]
-	profile := app.DB.GetUserProfile(username)
+	profile := db.GetUserProfile(username)
+	if profile.Profile.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorResponse("_", "User not found"))
+		return
+	}
 
 	userToken := r.Context().Value("user")
 	if userToken == nil {
@@ -57,9 +69,26 @@ func (app *App) FollowHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	app.DB.Follow(profile.Profile.ID, uint(loggedInUserID.(float64)))
+	userID := uint(loggedInUserID.(float64))
+	if userID == profile.Profile.ID {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("username", "can't be yourself"))
+		return
+	}
+
+	if db.IsBlocked(profile.Profile.ID, userID) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	db.Follow(userID, profile.Profile.ID)
+	app.publish(realtime.UserTopic(userID), realtime.SubscriptionsChanged, nil)
 
-	profile.Profile.Following = true
+	// Reload so that followersCount includes the change.
+	profile = db.GetUserProfile(username)
+	profile.Profile.Following = db.IsFollowing(userID, profile.Profile.ID)
+	profile.Profile.Blocking = db.IsBlocked(userID, profile.Profile.ID)
+	profile.Profile.Muting = db.IsMuted(userID, profile.Profile.ID)
 	resp, err := json.Marshal(&profile)
 	if err != nil {
 		w.WriteHeader(http.StatusUnprocessableEntity)
@@ -72,10 +101,16 @@ func (app *App) FollowHandler(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) UnfollowHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 
 	vars := mux.Vars(r)
 	username := vars["username"]
-	profile := app.DB.GetUserProfile(username)
+	profile := db.GetUserProfile(username)
+	if profile.Profile.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorResponse("_", "User not found"))
+		return
+	}
 
 	userToken := r.Context().Value("user")
 	if userToken == nil {
@@ -89,7 +124,142 @@ func (app *App) UnfollowHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	app.DB.Unfollow(profile.Profile.ID, uint(loggedInUserID.(float64)))
+	userID := uint(loggedInUserID.(float64))
+	if userID == profile.Profile.ID {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("username", "can't be yourself"))
+		return
+	}
+
+	db.Unfollow(userID, profile.Profile.ID)
+	app.publish(realtime.UserTopic(userID), realtime.SubscriptionsChanged, nil)
+
+	// Reload so that followersCount includes the change.
+	profile = db.GetUserProfile(username)
+	profile.Profile.Following = db.IsFollowing(userID, profile.Profile.ID)
+	profile.Profile.Blocking = db.IsBlocked(userID, profile.Profile.ID)
+	profile.Profile.Muting = db.IsMuted(userID, profile.Profile.ID)
+	resp, err := json.Marshal(&profile)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) FollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listProfiles(w, r, (*models.DB).ListFollowers)
+}
+
+func (app *App) FollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.listProfiles(w, r, (*models.DB).ListFollowing)
+}
+
+func (app *App) listProfiles(w http.ResponseWriter, r *http.Request,
+	list func(*models.DB, url.Values, uint) *models.ProfilesResponseJson) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+	profile := db.GetUserProfile(username)
+	if profile.Profile.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorResponse("_", "User not found"))
+		return
+	}
+
+	profiles := list(db, r.URL.Query(), profile.Profile.ID)
+
+	userToken := r.Context().Value("user")
+	if userToken != nil {
+		loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+		if loggedInUserID != nil {
+			db.SetFollowing(profiles.Profiles, uint(loggedInUserID.(float64)))
+		}
+	}
+
+	resp, err := json.Marshal(&profiles)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) BlockHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelation(w, r, func(db *models.DB, userID uint, profile *models.Profile) {
+		db.Block(userID, profile.ID)
+		app.publish(realtime.UserTopic(profile.ID), realtime.SubscriptionsChanged, nil)
+		profile.Blocking = true
+	})
+}
+
+func (app *App) UnblockHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelation(w, r, func(db *models.DB, userID uint, profile *models.Profile) {
+		db.Unblock(userID, profile.ID)
+		profile.Blocking = false
+	})
+}
+
+func (app *App) MuteHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelation(w, r, func(db *models.DB, userID uint, profile *models.Profile) {
+		db.Mute(userID, profile.ID)
+		profile.Muting = true
+	})
+}
+
+func (app *App) UnmuteHandler(w http.ResponseWriter, r *http.Request) {
+	app.updateRelation(w, r, func(db *models.DB, userID uint, profile *models.Profile) {
+		db.Unmute(userID, profile.ID)
+		profile.Muting = false
+	})
+}
+
+// updateRelation applies update between the logged in user and the
+// {username} profile, then responds with the profile as the logged in user
+// sees it.
+func (app *App) updateRelation(w http.ResponseWriter, r *http.Request,
+	update func(db *models.DB, userID uint, profile *models.Profile)) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+	profile := db.GetUserProfile(username)
+	if profile.Profile.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorResponse("_", "User not found"))
+		return
+	}
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	userID := uint(loggedInUserID.(float64))
+	if userID == profile.Profile.ID {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("username", "can't be yourself"))
+		return
+	}
+
+	profile.Profile.Blocking = db.IsBlocked(userID, profile.Profile.ID)
+	profile.Profile.Muting = db.IsMuted(userID, profile.Profile.ID)
+	update(db, userID, &profile.Profile)
+	profile.Profile.Following = db.IsFollowing(userID, profile.Profile.ID)
 
 	resp, err := json.Marshal(&profile)
 	if err != nil {
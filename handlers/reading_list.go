@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+
+	"github.com/koyoyo/realworld-starter-kit/models"
+)
+
+type ReadingListForm struct {
+	List struct {
+		Name   string `json:"name" validate:"required"`
+		Public *bool  `json:"public"`
+	} `json:"list"`
+}
+
+type ReadingListArticleForm struct {
+	Article struct {
+		Slug string `json:"slug" validate:"required"`
+	} `json:"article"`
+}
+
+type ReadingListOrderForm struct {
+	Slugs []string `json:"slugs" validate:"required"`
+}
+
+func (app *App) ArticleBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	app.bookmark(w, r, true)
+}
+
+func (app *App) ArticleUnbookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	app.bookmark(w, r, false)
+}
+
+func (app *App) bookmark(w http.ResponseWriter, r *http.Request, bookmarked bool) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+	article := db.GetArticleResponseFromSlug(slug)
+	if article.Article.Slug == "" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return
+	}
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	userID := uint(loggedInUserID.(float64))
+	if bookmarked {
+		db.BookmarkArticle(article.Article.ID, userID)
+	} else {
+		db.UnbookmarkArticle(article.Article.ID, userID)
+	}
+
+	article.Article.Bookmarked = bookmarked
+	article.Article.Favorited = db.IsFavorite(article.Article.ID, userID)
+	article.Article.Author.Following = db.IsFollowing(userID, article.Article.Author.ID)
+	resp, err := json.Marshal(&article)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) BookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	articles := db.ListBookmarks(r.URL.Query(), uint(loggedInUserID.(float64)))
+	resp, err := json.Marshal(&articles)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) ReadingListsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	lists := db.ListReadingLists(uint(loggedInUserID.(float64)))
+	resp, err := json.Marshal(&lists)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) ReadingListCreateHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	body := ReadingListForm{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	err = app.Validator.Struct(body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponseFromValidator(err))
+		return
+	}
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	public := body.List.Public != nil && *body.List.Public
+	list := db.CreateReadingList(uint(loggedInUserID.(float64)), body.List.Name, public)
+	resp, err := json.Marshal(&list)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(resp)
+}
+
+func (app *App) ReadingListDetailHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	list, userID, ok := ownReadingList(w, r, db)
+	if !ok {
+		return
+	}
+
+	listResponse := db.PrepareReadingListResponse(list, userID)
+	resp, err := json.Marshal(&listResponse)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) ReadingListUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	body := ReadingListForm{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	list, _, ok := ownReadingList(w, r, db)
+	if !ok {
+		return
+	}
+
+	listResponse := db.UpdateReadingList(list, body.List.Name, body.List.Public)
+	resp, err := json.Marshal(&listResponse)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) ReadingListDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	list, _, ok := ownReadingList(w, r, db)
+	if !ok {
+		return
+	}
+
+	db.DeleteReadingList(list)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *App) ReadingListAddArticleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	body := ReadingListArticleForm{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	err = app.Validator.Struct(body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponseFromValidator(err))
+		return
+	}
+
+	list, userID, ok := ownReadingList(w, r, db)
+	if !ok {
+		return
+	}
+
+	article := db.GetArticleFromSlug(body.Article.Slug)
+	if article.Slug == "" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return
+	}
+
+	db.AddToReadingList(list, article.ID)
+	listResponse := db.PrepareReadingListResponse(list, userID)
+	resp, err := json.Marshal(&listResponse)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) ReadingListRemoveArticleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	list, userID, ok := ownReadingList(w, r, db)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	article := db.GetArticleFromSlug(vars["slug"])
+	if article.Slug == "" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return
+	}
+
+	db.RemoveFromReadingList(list, article.ID)
+	listResponse := db.PrepareReadingListResponse(list, userID)
+	resp, err := json.Marshal(&listResponse)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) ReadingListReorderHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	body := ReadingListOrderForm{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	list, userID, ok := ownReadingList(w, r, db)
+	if !ok {
+		return
+	}
+
+	if err := db.ReorderReadingList(list, body.Slugs); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("slugs", err.Error()))
+		return
+	}
+
+	listResponse := db.PrepareReadingListResponse(list, userID)
+	resp, err := json.Marshal(&listResponse)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+// SharedReadingListHandler shows a public reading list to anyone with its
+// share link.
+func (app *App) SharedReadingListHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	vars := mux.Vars(r)
+	list := db.GetSharedReadingList(vars["shareToken"])
+	if list.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return
+	}
+
+	var userID uint
+	if userToken := r.Context().Value("user"); userToken != nil {
+		if loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]; loggedInUserID != nil {
+			userID = uint(loggedInUserID.(float64))
+		}
+	}
+
+	listResponse := db.PrepareReadingListResponse(list, userID)
+	if userID != list.OwnerID {
+		listResponse.List.ShareToken = nil
+	}
+	resp, err := json.Marshal(&listResponse)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+// ownReadingList loads the {listID} reading list of the logged in user,
+// writing the error response itself when it can't.
+func ownReadingList(w http.ResponseWriter, r *http.Request, db *models.DB) (*models.ReadingList, uint, bool) {
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, 0, false
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, 0, false
+	}
+
+	vars := mux.Vars(r)
+	listID, err := strconv.Atoi(vars["listID"])
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return nil, 0, false
+	}
+
+	userID := uint(loggedInUserID.(float64))
+	list := db.GetReadingList(uint(listID), userID)
+	if list.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return nil, 0, false
+	}
+
+	return list, userID, true
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+	"github.com/koyoyo/realworld-starter-kit/models"
+)
+
+func (app *App) ArticleRelatedHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	vars := mux.Vars(r)
+	article := db.GetArticleFromSlug(vars["slug"])
+	if article.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return
+	}
+
+	var articles *models.ArticlesResponseJson
+
+	if userToken := r.Context().Value("user"); userToken != nil {
+		if loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]; loggedInUserID != nil {
+			articles = db.ListRelatedArticlesWithUser(r.URL.Query(), article.ID, uint(loggedInUserID.(float64)))
+		} else {
+			articles = db.ListRelatedArticles(r.URL.Query(), article.ID)
+		}
+	} else {
+		articles = db.ListRelatedArticles(r.URL.Query(), article.ID)
+	}
+
+	resp, err := json.Marshal(&articles)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) RecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	articles := db.ListRecommendations(r.URL.Query(), uint(loggedInUserID.(float64)))
+	resp, err := json.Marshal(&articles)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+	"github.com/koyoyo/realworld-starter-kit/models"
+)
+
+type ReportForm struct {
+	Report struct {
+		TargetType string `json:"targetType" validate:"required,oneof=article comment user"`
+		// Target is the article slug, the comment id or the username.
+		Target string `json:"target" validate:"required"`
+		Reason string `json:"reason" validate:"required,max=1000"`
+	} `json:"report"`
+}
+
+type ReportStateForm struct {
+	Report struct {
+		State string `json:"state" validate:"required,oneof=actioned dismissed"`
+	} `json:"report"`
+}
+
+func (app *App) ReportCreateHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	body := ReportForm{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	err = app.Validator.Struct(body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponseFromValidator(err))
+		return
+	}
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var targetID uint
+	switch body.Report.TargetType {
+	case models.ReportArticle:
+		targetID = db.GetArticleFromSlug(body.Report.Target).ID
+	case models.ReportComment:
+		if commentID, err := strconv.ParseUint(body.Report.Target, 10, 0); err == nil && commentID > 0 {
+			targetID = db.GetComment(uint(commentID)).ID
+		}
+	case models.ReportUser:
+		targetID = db.GetUserFromUsername(body.Report.Target).User.ID
+	}
+	if targetID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return
+	}
+
+	report := db.CreateReport(uint(loggedInUserID.(float64)), body.Report.TargetType, targetID, body.Report.Reason,
+		app.Config.ReportHideThreshold)
+	resp, err := json.Marshal(&report)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(resp)
+}
+
+func (app *App) ModerationReportsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	if _, ok := moderator(w, r, db); !ok {
+		return
+	}
+
+	resp, err := json.Marshal(db.ListReports(r.URL.Query()))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) ModerationReportUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	body := ReportStateForm{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	err = app.Validator.Struct(body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponseFromValidator(err))
+		return
+	}
+
+	moderatorID, ok := moderator(w, r, db)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	reportID, err := strconv.Atoi(vars["reportID"])
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	report := db.GetReport(uint(reportID))
+	if report.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return
+	}
+
+	resp, err := json.Marshal(db.ResolveReport(report, body.Report.State, moderatorID))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+// moderator returns the id of the logged in admin, writing the error
+// response itself when the user isn't one.
+func moderator(w http.ResponseWriter, r *http.Request, db *models.DB) (uint, bool) {
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return 0, false
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return 0, false
+	}
+
+	if !db.IsAdmin(uint(loggedInUserID.(float64))) {
+		w.WriteHeader(http.StatusForbidden)
+		return 0, false
+	}
+
+	return uint(loggedInUserID.(float64)), true
+}
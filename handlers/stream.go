@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/koyoyo/realworld-starter-kit/models"
+	"github.com/koyoyo/realworld-starter-kit/realtime"
+)
+
+const streamKeepAlive = 25 * time.Second
+
+// streamSentArticles bounds the articles a stream remembers to drop the
+// copies it receives through several topics.
+const streamSentArticles = 256
+
+// StreamHandler pushes Server-Sent Events to the logged in user: their
+// notifications, new articles from the authors and tags they follow, and new
+// comments on the articles listed in ?articles=slug1,slug2. Events carry
+// references only; clients fetch the content from the API.
+func (app *App) StreamHandler(w http.ResponseWriter, r *http.Request) {
+	db := app.DB.WithContext(r.Context())
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotImplemented)
+		w.Write(JsonErrorResponse("_", "Streaming unsupported."))
+		return
+	}
+
+	userID := uint(loggedInUserID.(float64))
+	var slugs []string
+	if articles := r.URL.Query().Get("articles"); articles != "" {
+		slugs = strings.Split(articles, ",")
+	}
+
+	sub := app.Hub.Subscribe(streamTopics(db, userID, slugs)...)
+	defer func() { sub.Close() }()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	// An article by a followed author with followed tags arrives once per
+	// topic; only the first copy is sent.
+	sent := map[string]bool{}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if msg.Event == realtime.SubscriptionsChanged {
+				// Subscribe before closing so nothing is missed in between.
+				old := sub
+				sub = app.Hub.Subscribe(streamTopics(db, userID, slugs)...)
+				old.Close()
+				continue
+			}
+			if msg.Event == "article.created" {
+				if sent[string(msg.Data)] {
+					continue
+				}
+				if len(sent) >= streamSentArticles {
+					sent = map[string]bool{}
+				}
+				sent[string(msg.Data)] = true
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Event, msg.Data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// streamTopics lists the topics a stream of userID follows: their own, those
+// of the authors and tags they follow, and those of the given articles.
+func streamTopics(db *models.DB, userID uint, slugs []string) []string {
+	topics := []string{realtime.UserTopic(userID)}
+	for _, authorID := range db.FollowingIDs(userID) {
+		topics = append(topics, realtime.AuthorTopic(authorID))
+	}
+	for _, tag := range db.FollowedTagNames(userID) {
+		topics = append(topics, realtime.TagTopic(tag))
+	}
+	for _, slug := range slugs {
+		topics = append(topics, realtime.ArticleTopic(slug))
+	}
+	return topics
+}
+
+// publish sends a realtime message, logging rather than failing the request
+// when it can't: the change itself has already been saved.
+func (app *App) publish(topic, event string, data interface{}) {
+	if err := app.Hub.Publish(topic, event, data); err != nil {
+		log.Printf("Publish %s to %s: %s", event, topic, err)
+	}
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"golang.org/x/image/draw"
+
+	"github.com/koyoyo/realworld-starter-kit/models"
+)
+
+const (
+	avatarMaxSize = 2 << 20
+	imageMaxSize  = 8 << 20
+	// uploadMaxPixels bounds width×height before decoding: a few KB of PNG
+	// can declare an image that takes gigabytes once decoded.
+	uploadMaxPixels = 25 * 1000 * 1000
+)
+
+// uploadTypes maps the sniffed content types we accept to a file extension.
+var uploadTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// uploadVariants lists, per asset kind, the resized copies to generate and
+// their maximum width in pixels.
+var uploadVariants = map[string]map[string]int{
+	models.AvatarAsset: {
+		"small":  64,
+		"medium": 256,
+	},
+	models.ImageAsset: {
+		"thumbnail": 320,
+		"medium":    1024,
+	},
+}
+
+func (app *App) AvatarUploadHandler(w http.ResponseWriter, r *http.Request) {
+	app.upload(w, r, models.AvatarAsset, avatarMaxSize)
+}
+
+func (app *App) ImageUploadHandler(w http.ResponseWriter, r *http.Request) {
+	app.upload(w, r, models.ImageAsset, imageMaxSize)
+}
+
+func (app *App) upload(w http.ResponseWriter, r *http.Request, kind string, maxSize int64) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	// Leave some room for the multipart envelope around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20)
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write(JsonErrorResponse("file", err.Error()))
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("file", "required"))
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write(JsonErrorResponse("file", fmt.Sprintf("must be at most %d bytes", maxSize)))
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("file", err.Error()))
+		return
+	}
+	if int64(len(data)) > maxSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write(JsonErrorResponse("file", fmt.Sprintf("must be at most %d bytes", maxSize)))
+		return
+	}
+
+	// Trust the bytes, not the client's Content-Type header.
+	contentType := http.DetectContentType(data)
+	ext, ok := uploadTypes[contentType]
+	if !ok {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		w.Write(JsonErrorResponse("file", "unsupported type "+contentType))
+		return
+	}
+
+	imageConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("file", "is not a valid image"))
+		return
+	}
+	if imageConfig.Width <= 0 || imageConfig.Height <= 0 || int64(imageConfig.Width)*int64(imageConfig.Height) > uploadMaxPixels {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("file", fmt.Sprintf("must be at most %d pixels", uploadMaxPixels)))
+		return
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("file", "is not a valid image"))
+		return
+	}
+
+	userID := uint(loggedInUserID.(float64))
+	base := fmt.Sprintf("%ss/%d/%s", kind, userID, randomName())
+	key := base + ext
+	if err := app.Store.Put(r.Context(), key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+	stored := []string{key}
+
+	variants := map[string]string{}
+	for name, width := range uploadVariants[kind] {
+		resized, variantType, variantExt, err := resize(img, width, contentType)
+		if err != nil {
+			app.deleteBlobs(stored)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write(JsonErrorResponse("file", err.Error()))
+			return
+		}
+
+		variantKey := base + "_" + name + variantExt
+		if err := app.Store.Put(r.Context(), variantKey, bytes.NewReader(resized), int64(len(resized)),
+			variantType); err != nil {
+			app.deleteBlobs(stored)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(JsonErrorResponse("_", err.Error()))
+			return
+		}
+		stored = append(stored, variantKey)
+		variants[name] = variantKey
+	}
+
+	asset := db.CreateAsset(userID, kind, contentType, int64(len(data)), key, variants)
+	resp, err := json.Marshal(db.PrepareAssetResponse(asset, app.Store.URL))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(resp)
+}
+
+// deleteBlobs removes the blobs of an upload that failed half way. It doesn't
+// use the request's context, which is likely done by then.
+func (app *App) deleteBlobs(keys []string) {
+	for _, key := range keys {
+		app.Store.Delete(context.Background(), key)
+	}
+}
+
+// resize scales img down to at most width pixels wide, keeping its aspect
+// ratio. JPEGs stay JPEGs; everything else is re-encoded as PNG.
+func resize(img image.Image, width int, contentType string) ([]byte, string, string, error) {
+	bounds := img.Bounds()
+	if bounds.Dx() > width {
+		height := bounds.Dy() * width / bounds.Dx()
+		if height < 1 {
+			height = 1
+		}
+		dst := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+		img = dst
+	}
+
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+		return buf.Bytes(), "image/jpeg", ".jpg", err
+	}
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), "image/png", ".png", err
+}
+
+func randomName() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Errorf("Random name err: %s", err))
+	}
+	return hex.EncodeToString(buf)
+}
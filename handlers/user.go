@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/koyoyo/realworld-starter-kit/models"
 )
 
 type RegisterUser struct {
@@ -29,11 +30,14 @@ type UpdateUser struct {
 		Password string  `json:"password"`
 		Bio      string  `json:"bio"`
 		Image    *string `json:"image" validate:"url"`
+		// ImageAssetID sets the image from an uploaded avatar instead of a URL.
+		ImageAssetID *uint `json:"imageAssetId"`
 	} `json:"user"`
 }
 
 func (app *App) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 	r.ParseForm()
 
 	body := RegisterUser{}
@@ -51,8 +55,14 @@ func (app *App) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newUser := app.DB.CreateUser(body.User.Username, body.User.Email, body.User.Password)
-	newUser.User.NewToken()
+	if models.IsReservedUsername(body.User.Username) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("username", "is reserved"))
+		return
+	}
+
+	newUser := db.CreateUser(body.User.Username, body.User.Email, body.User.Password)
+	newUser.User.NewToken([]byte(app.Config.JWTSignedKey))
 
 	resp, err := json.Marshal(&newUser)
 	if err != nil {
@@ -66,6 +76,7 @@ func (app *App) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 	r.ParseForm()
 
 	body := LoginUser{}
@@ -83,7 +94,7 @@ func (app *App) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user := app.DB.GetUserFromEmail(body.User.Email)
+	user := db.GetUserFromEmail(body.User.Email)
 	if user.User.ID == 0 {
 		w.WriteHeader(http.StatusUnprocessableEntity)
 		w.Write(JsonErrorResponse("email", "is invalid"))
@@ -97,7 +108,14 @@ func (app *App) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user.User.NewToken()
+	// The tokens were revoked when the deletion was requested.
+	if db.IsDeletingAccount(user.User.ID) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write(JsonErrorResponse("_", "Account deletion in progress."))
+		return
+	}
+
+	user.User.NewToken([]byte(app.Config.JWTSignedKey))
 	resp, err := json.Marshal(&user)
 	if err != nil {
 		w.WriteHeader(http.StatusUnprocessableEntity)
@@ -110,6 +128,7 @@ func (app *App) LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) GetUserHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 
 	userToken := r.Context().Value("user")
 	username := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["Username"]
@@ -119,7 +138,7 @@ func (app *App) GetUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user := app.DB.GetUserFromUsername(username.(string))
+	user := db.GetUserFromUsername(username.(string))
 	user.User.Token = userToken.(*jwt.Token).Raw
 	resp, err := json.Marshal(&user)
 	if err != nil {
@@ -133,6 +152,7 @@ func (app *App) GetUserHandler(w http.ResponseWriter, r *http.Request) {
 
 func (app *App) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
 
 	body := UpdateUser{}
 	err := json.NewDecoder(r.Body).Decode(&body)
@@ -157,8 +177,27 @@ func (app *App) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user := app.DB.GetUserFromUsername(username.(string))
-	updatedUser := app.DB.UpdateUser(&user.User, body.User.Username, body.User.Email, body.User.Password, body.User.Bio,
+	user := db.GetUserFromUsername(username.(string))
+	if body.User.Username != "" && body.User.Username != user.User.Username &&
+		models.IsReservedUsername(body.User.Username) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("username", "is reserved"))
+		return
+	}
+
+	if body.User.ImageAssetID != nil {
+		asset := db.GetAsset(*body.User.ImageAssetID, user.User.ID)
+		if asset.ID == 0 || asset.Kind != models.AvatarAsset {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write(JsonErrorResponse("imageAssetId", "is invalid"))
+			return
+		}
+
+		image := app.Store.URL(asset.VariantKey("medium"))
+		body.User.Image = &image
+	}
+
+	updatedUser := db.UpdateUser(&user.User, body.User.Username, body.User.Email, body.User.Password, body.User.Bio,
 		body.User.Image)
 	updatedUser.User.Token = userToken.(*jwt.Token).Raw
 
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+
+	"github.com/koyoyo/realworld-starter-kit/models"
+	"github.com/koyoyo/realworld-starter-kit/webhooks"
+)
+
+type WebhookForm struct {
+	Webhook struct {
+		URL    string   `json:"url" validate:"required,url"`
+		Events []string `json:"events"`
+		Global bool     `json:"global"`
+	} `json:"webhook"`
+}
+
+func (app *App) WebhookListHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	webhooks := db.ListWebhooks(uint(loggedInUserID.(float64)))
+	resp, err := json.Marshal(&webhooks)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+func (app *App) WebhookCreateHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	body := WebhookForm{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	err = app.Validator.Struct(body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponseFromValidator(err))
+		return
+	}
+
+	if err := webhooks.ValidateURL(body.Webhook.URL); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("url", err.Error()))
+		return
+	}
+
+	for _, event := range body.Webhook.Events {
+		if !isWebhookEvent(event) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write(JsonErrorResponse("events", "unknown event "+event))
+			return
+		}
+	}
+
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	userID := uint(loggedInUserID.(float64))
+	if body.Webhook.Global && !db.IsAdmin(userID) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	webhook := db.CreateWebhook(userID, body.Webhook.URL, body.Webhook.Events, body.Webhook.Global)
+	resp, err := json.Marshal(&webhook)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(resp)
+}
+
+func (app *App) WebhookDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	webhook, ok := ownWebhook(w, r, db)
+	if !ok {
+		return
+	}
+
+	db.DeleteWebhook(webhook)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *App) WebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	db := app.DB.WithContext(r.Context())
+
+	webhook, ok := ownWebhook(w, r, db)
+	if !ok {
+		return
+	}
+
+	deliveries := db.ListWebhookDeliveries(r.URL.Query(), webhook.ID)
+	resp, err := json.Marshal(&deliveries)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return
+	}
+
+	w.Write(resp)
+}
+
+// ownWebhook loads the {webhookID} webhook of the logged in user, writing the
+// error response itself when it can't.
+func ownWebhook(w http.ResponseWriter, r *http.Request, db *models.DB) (*models.Webhook, bool) {
+	userToken := r.Context().Value("user")
+	if userToken == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, false
+	}
+
+	loggedInUserID := userToken.(*jwt.Token).Claims.(jwt.MapClaims)["UserID"]
+	if loggedInUserID == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, false
+	}
+
+	vars := mux.Vars(r)
+	webhookID, err := strconv.Atoi(vars["webhookID"])
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write(JsonErrorResponse("_", err.Error()))
+		return nil, false
+	}
+
+	webhook := db.GetWebhook(uint(webhookID), uint(loggedInUserID.(float64)))
+	if webhook.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(JsonErrorNotFoundResponse())
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+func isWebhookEvent(event string) bool {
+	for _, e := range models.WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/koyoyo/realworld-starter-kit/models"
+	"github.com/koyoyo/realworld-starter-kit/storage"
+)
+
+// AccountDeletion works through the queued account deletions and removes
+// the deleted users' uploads from the blob store.
+type AccountDeletion struct {
+	DB       *models.DB
+	Store    storage.BlobStore
+	Interval time.Duration
+}
+
+func NewAccountDeletion(db *models.DB, store storage.BlobStore) *AccountDeletion {
+	return &AccountDeletion{
+		DB:       db,
+		Store:    store,
+		Interval: 30 * time.Second,
+	}
+}
+
+// Run polls for queued deletions every Interval until ctx is done.
+func (a *AccountDeletion) Run(ctx context.Context) {
+	Every(ctx, a.Interval, a.drain)
+}
+
+func (a *AccountDeletion) drain(ctx context.Context) {
+	db := a.DB.WithContext(ctx)
+	for ctx.Err() == nil {
+		deletion := db.ClaimAccountDeletion()
+		if deletion == nil {
+			return
+		}
+
+		blobKeys, err := db.DeleteAccount(deletion)
+		if err != nil {
+			log.Printf("Delete account of user %d: %s", deletion.UserID, err)
+			continue
+		}
+		for _, key := range blobKeys {
+			if err := a.Store.Delete(ctx, key); err != nil {
+				log.Printf("Delete blob %s of user %d: %s", key, deletion.UserID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+// Package jobs runs periodic maintenance work alongside the server.
+package jobs
+
+import (
+	"context"
+	"time"
+)
+
+// Every calls run straight away and then once per interval until ctx is done.
+func Every(ctx context.Context, interval time.Duration, run func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		run(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
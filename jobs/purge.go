@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/koyoyo/realworld-starter-kit/models"
+)
+
+// Purge empties the trash of articles and comments deleted longer than
+// Retention ago.
+type Purge struct {
+	DB        *models.DB
+	Interval  time.Duration
+	Retention time.Duration
+}
+
+func NewPurge(db *models.DB, retention time.Duration) *Purge {
+	return &Purge{
+		DB:        db,
+		Interval:  time.Hour,
+		Retention: retention,
+	}
+}
+
+// Run purges every Interval until ctx is done.
+func (p *Purge) Run(ctx context.Context) {
+	Every(ctx, p.Interval, func(ctx context.Context) {
+		if _, _, err := p.DB.WithContext(ctx).PurgeTrash(time.Now().Add(-p.Retention)); err != nil {
+			log.Printf("Purge trash: %s", err)
+		}
+	})
+}
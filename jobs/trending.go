@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/koyoyo/realworld-starter-kit/models"
+)
+
+// Trending keeps the materialized articles.trending_score fresh so that
+// ?sort=trending stays an index scan.
+type Trending struct {
+	DB       *models.DB
+	Interval time.Duration
+	HalfLife time.Duration
+}
+
+func NewTrending(db *models.DB) *Trending {
+	return &Trending{
+		DB:       db,
+		Interval: 10 * time.Minute,
+		HalfLife: 24 * time.Hour,
+	}
+}
+
+// Run refreshes the scores every Interval until ctx is done.
+func (t *Trending) Run(ctx context.Context) {
+	Every(ctx, t.Interval, func(ctx context.Context) {
+		if _, err := t.DB.WithContext(ctx).RefreshTrendingScores(t.HalfLife); err != nil {
+			log.Printf("Refresh trending: %s", err)
+		}
+	})
+}
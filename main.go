@@ -1,128 +1,440 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
-	"github.com/spf13/viper"
 	"github.com/urfave/negroni"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 	validator "gopkg.in/go-playground/validator.v9"
 
+	"github.com/koyoyo/realworld-starter-kit/config"
+	"github.com/koyoyo/realworld-starter-kit/events"
 	"github.com/koyoyo/realworld-starter-kit/handlers"
+	"github.com/koyoyo/realworld-starter-kit/jobs"
 	"github.com/koyoyo/realworld-starter-kit/models"
+	"github.com/koyoyo/realworld-starter-kit/moderation"
+	"github.com/koyoyo/realworld-starter-kit/realtime"
+	"github.com/koyoyo/realworld-starter-kit/storage"
+	"github.com/koyoyo/realworld-starter-kit/tracing"
+	"github.com/koyoyo/realworld-starter-kit/webhooks"
 )
 
+// version is overridden at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
 func main() {
-	if os.Getenv("ENVIRONMENT") == "DEV" {
-		viper.SetConfigName("config")
-		viper.SetConfigType("toml")
-		viper.AddConfigPath(".")
-		err := viper.ReadInConfig() // Find and read the config file
-		if err != nil {             // Handle errors reading the config file
-			panic(fmt.Errorf("Fatal error config file: %s \n", err))
-		}
-	} else {
-		viper.AutomaticEnv()
+	flags := config.NewFlagSet(os.Args[0])
+	flags.Usage = usage
+	flags.Parse(os.Args[1:])
+
+	name, args := "serve", flags.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+	if cmd.Run == nil {
+		usage()
+		return
+	}
+
+	cfg, err := config.Load(flags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, version)
+	if err != nil {
+		panic(fmt.Errorf("Fatal tracing setup: %s \n", err))
 	}
+	defer shutdownTracing(context.Background())
 
-	db, err := gorm.Open("postgres", viper.Get("POSTGRES_URL"))
+	db, err := gorm.Open("postgres", cfg.PostgresURL)
 	if err != nil {
 		panic(fmt.Errorf("Fatal db connect: %s \n", err))
 	}
 	defer db.Close()
+	models.RegisterTracing(db)
 
-	// Initial Schema
-	db.AutoMigrate(&models.User{})
-	db.AutoMigrate(&models.Follower{})
-	db.AutoMigrate(&models.Article{})
-	db.AutoMigrate(&models.ArticleFavorite{})
-	db.AutoMigrate(&models.ArticleComment{})
-	db.AutoMigrate(&models.Tag{})
+	cmd.Run(cfg, &models.DB{DB: db}, args)
+}
 
-	fmt.Println("Hello World!!")
+// serve runs the API server and its background jobs until SIGINT or SIGTERM.
+func serve(cfg *config.Config, db *models.DB, args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	autoMigrate := flags.Bool("migrate", true, "migrate the schema before serving")
+	flags.Parse(args)
+
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		panic(fmt.Errorf("Fatal blob store: %s \n", err))
+	}
+
+	hub, err := realtime.New(cfg.RealtimeBackend, cfg.PostgresURL)
+	if err != nil {
+		panic(fmt.Errorf("Fatal realtime hub: %s \n", err))
+	}
+	defer hub.Close()
+
+	filter, err := moderation.New(cfg.ContentFilter, cfg.ContentFilterFile)
+	if err != nil {
+		panic(fmt.Errorf("Fatal content filter: %s \n", err))
+	}
 
 	app := handlers.App{
-		DB: models.DB{
-			db,
-		},
+		Config:    cfg,
+		DB:        *db,
 		Validator: validator.New(),
+		Store:     store,
+		Hub:       hub,
+		Filter:    filter,
+		Version:   version,
+	}
+
+	// Initial Schema
+	if *autoMigrate {
+		app.DB.Migrate()
+	}
+	jwt := newJwtMiddlewares(cfg, app.DB.TokenRevoked)
+
+	models.NotificationHook = func(notification *models.Notification) {
+		if err := hub.Publish(realtime.UserTopic(notification.UserID), "notification",
+			realtime.NotificationRef{ID: notification.ID, Kind: notification.Kind}); err != nil {
+			log.Printf("Publish notification %d: %s", notification.ID, err)
+		}
 	}
 
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	bus := events.NewBus()
+	app.DB.Subscribe(bus)
+	go events.NewDispatcher(app.DB.DB, bus).Run(background)
+	go webhooks.NewDispatcher(&app.DB).Run(background)
+	go jobs.NewTrending(&app.DB).Run(background)
+	go jobs.NewPurge(&app.DB, cfg.TrashRetention).Run(background)
+	go jobs.NewAccountDeletion(&app.DB, store).Run(background)
+
+	fmt.Println("Hello World!!")
+
 	r := mux.NewRouter()
+	r.Use(otelmux.Middleware(cfg.Tracing.ServiceName))
+	r.HandleFunc("/healthz", app.HealthHandler).Methods("GET")
+	r.HandleFunc("/readyz", app.ReadyHandler).Methods("GET")
 	r.Handle("/api/user", negroni.New(
-		negroni.HandlerFunc(JwtRequiredMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
 		negroni.WrapFunc(app.GetUserHandler),
 	)).Methods("GET")
 	r.Handle("/api/user", negroni.New(
-		negroni.HandlerFunc(JwtRequiredMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
 		negroni.WrapFunc(app.UpdateUserHandler),
 	)).Methods("PUT")
+	r.Handle("/api/user", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.UserDeleteHandler),
+	)).Methods("DELETE")
+	r.Handle("/api/user/export", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.UserExportHandler),
+	)).Methods("GET")
+	r.Handle("/api/user/articles/import", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ArticleImportHandler),
+	)).Methods("POST")
+	r.Handle("/api/user/articles/export", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ArticleExportHandler),
+	)).Methods("GET")
+	r.HandleFunc("/api/account-deletions/{token}", app.AccountDeletionHandler).Methods("GET")
 	r.HandleFunc("/api/users", app.RegisterHandler)
 	r.HandleFunc("/api/users/login", app.LoginHandler)
 
 	r.Handle("/api/profiles/{username}", negroni.New(
-		negroni.HandlerFunc(JwtOptionalMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Optional.HandlerWithNext),
 		negroni.WrapFunc(app.GetUserProfileHandler),
 	))
 	r.Handle("/api/profiles/{username}/follow", negroni.New(
-		negroni.HandlerFunc(JwtRequiredMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
 		negroni.WrapFunc(app.FollowHandler),
 	)).Methods("POST")
 	r.Handle("/api/profiles/{username}/follow", negroni.New(
-		negroni.HandlerFunc(JwtRequiredMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
 		negroni.WrapFunc(app.UnfollowHandler),
 	)).Methods("DELETE")
+	r.Handle("/api/profiles/{username}/block", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.BlockHandler),
+	)).Methods("POST")
+	r.Handle("/api/profiles/{username}/block", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.UnblockHandler),
+	)).Methods("DELETE")
+	r.Handle("/api/profiles/{username}/mute", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.MuteHandler),
+	)).Methods("POST")
+	r.Handle("/api/profiles/{username}/mute", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.UnmuteHandler),
+	)).Methods("DELETE")
+	r.Handle("/api/profiles/{username}/followers", negroni.New(
+		negroni.HandlerFunc(jwt.Optional.HandlerWithNext),
+		negroni.WrapFunc(app.FollowersHandler),
+	)).Methods("GET")
+	r.Handle("/api/profiles/{username}/following", negroni.New(
+		negroni.HandlerFunc(jwt.Optional.HandlerWithNext),
+		negroni.WrapFunc(app.FollowingHandler),
+	)).Methods("GET")
 
 	r.Handle("/api/articles", negroni.New(
-		negroni.HandlerFunc(JwtRequiredMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
 		negroni.WrapFunc(app.ArticleCreateHandler),
 	)).Methods("POST")
 	r.Handle("/api/articles", negroni.New(
-		negroni.HandlerFunc(JwtOptionalMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Optional.HandlerWithNext),
 		negroni.WrapFunc(app.ArticleListHandler),
 	)).Methods("GET")
 	r.Handle("/api/articles/feed", negroni.New(
-		negroni.HandlerFunc(JwtRequiredMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
 		negroni.WrapFunc(app.ArticleFeedHandler),
 	)).Methods("GET")
 	r.Handle("/api/articles/{slug}", negroni.New(
-		negroni.HandlerFunc(JwtOptionalMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Optional.HandlerWithNext),
 		negroni.WrapFunc(app.ArticleDetailHandler),
 	)).Methods("GET")
 	r.Handle("/api/articles/{slug}", negroni.New(
-		negroni.HandlerFunc(JwtRequiredMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
 		negroni.WrapFunc(app.ArticleUpdateHandler),
 	)).Methods("PUT")
 	r.Handle("/api/articles/{slug}", negroni.New(
-		negroni.HandlerFunc(JwtRequiredMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
 		negroni.WrapFunc(app.ArticleDeleteHandler),
 	)).Methods("DELETE")
 	r.Handle("/api/articles/{slug}/favorite", negroni.New(
-		negroni.HandlerFunc(JwtRequiredMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
 		negroni.WrapFunc(app.ArticleFavoriteHandler),
 	)).Methods("POST")
 	r.Handle("/api/articles/{slug}/favorite", negroni.New(
-		negroni.HandlerFunc(JwtRequiredMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
 		negroni.WrapFunc(app.ArticleUnfavoriteHandler),
 	)).Methods("DELETE")
 	r.Handle("/api/articles/{slug}/comments", negroni.New(
-		negroni.HandlerFunc(JwtRequiredMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
 		negroni.WrapFunc(app.ArticleCommentAddHandler),
 	)).Methods("POST")
 	r.Handle("/api/articles/{slug}/comments", negroni.New(
-		negroni.HandlerFunc(JwtOptionalMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Optional.HandlerWithNext),
 		negroni.WrapFunc(app.ArticleCommentListHandler),
 	)).Methods("GET")
 	r.Handle("/api/articles/{slug}/comments/{commentID:[0-9]+}", negroni.New(
-		negroni.HandlerFunc(JwtRequiredMiddleware.HandlerWithNext),
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
 		negroni.WrapFunc(app.ArticleCommentDeleteHandler),
 	)).Methods("DELETE")
-	r.HandleFunc("/api/tags", app.TagsHandler)
+	r.Handle("/api/articles/{slug}/related", negroni.New(
+		negroni.HandlerFunc(jwt.Optional.HandlerWithNext),
+		negroni.WrapFunc(app.ArticleRelatedHandler),
+	)).Methods("GET")
+	r.Handle("/api/user/recommendations", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.RecommendationsHandler),
+	)).Methods("GET")
+	r.Handle("/api/tags", negroni.New(
+		negroni.HandlerFunc(jwt.Optional.HandlerWithNext),
+		negroni.WrapFunc(app.TagsHandler),
+	)).Methods("GET")
+	r.Handle("/api/tags/{tag}", negroni.New(
+		negroni.HandlerFunc(jwt.Optional.HandlerWithNext),
+		negroni.WrapFunc(app.TagDetailHandler),
+	)).Methods("GET")
+	r.Handle("/api/tags/{tag}", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.TagUpdateHandler),
+	)).Methods("PUT")
+	r.Handle("/api/tags/{tag}/follow", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.TagFollowHandler),
+	)).Methods("POST")
+	r.Handle("/api/tags/{tag}/follow", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.TagUnfollowHandler),
+	)).Methods("DELETE")
+	r.Handle("/api/articles/{slug}/bookmark", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ArticleBookmarkHandler),
+	)).Methods("POST")
+	r.Handle("/api/articles/{slug}/bookmark", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ArticleUnbookmarkHandler),
+	)).Methods("DELETE")
+	r.Handle("/api/user/bookmarks", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.BookmarksHandler),
+	)).Methods("GET")
+	r.Handle("/api/user/lists", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ReadingListsHandler),
+	)).Methods("GET")
+	r.Handle("/api/user/lists", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ReadingListCreateHandler),
+	)).Methods("POST")
+	r.Handle("/api/user/lists/{listID:[0-9]+}", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ReadingListDetailHandler),
+	)).Methods("GET")
+	r.Handle("/api/user/lists/{listID:[0-9]+}", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ReadingListUpdateHandler),
+	)).Methods("PUT")
+	r.Handle("/api/user/lists/{listID:[0-9]+}", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ReadingListDeleteHandler),
+	)).Methods("DELETE")
+	r.Handle("/api/user/lists/{listID:[0-9]+}/articles", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ReadingListAddArticleHandler),
+	)).Methods("POST")
+	r.Handle("/api/user/lists/{listID:[0-9]+}/articles", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ReadingListReorderHandler),
+	)).Methods("PUT")
+	r.Handle("/api/user/lists/{listID:[0-9]+}/articles/{slug}", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ReadingListRemoveArticleHandler),
+	)).Methods("DELETE")
+	r.Handle("/api/user/trash", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.TrashHandler),
+	)).Methods("GET")
+	r.Handle("/api/user/trash/articles/{slug}/restore", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.TrashArticleRestoreHandler),
+	)).Methods("POST")
+	r.Handle("/api/user/trash/comments/{commentID:[0-9]+}/restore", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.TrashCommentRestoreHandler),
+	)).Methods("POST")
+	r.Handle("/api/notifications", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.NotificationListHandler),
+	)).Methods("GET")
+	r.Handle("/api/notifications/read", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.NotificationReadAllHandler),
+	)).Methods("POST")
+	r.Handle("/api/notifications/{notificationID:[0-9]+}/read", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.NotificationReadHandler),
+	)).Methods("POST")
+	r.Handle("/api/user/notification-preferences", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.GetNotificationPreferenceHandler),
+	)).Methods("GET")
+	r.Handle("/api/user/notification-preferences", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.UpdateNotificationPreferenceHandler),
+	)).Methods("PUT")
+	r.Handle("/api/webhooks", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.WebhookListHandler),
+	)).Methods("GET")
+	r.Handle("/api/webhooks", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.WebhookCreateHandler),
+	)).Methods("POST")
+	r.Handle("/api/webhooks/{webhookID:[0-9]+}", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.WebhookDeleteHandler),
+	)).Methods("DELETE")
+	r.Handle("/api/webhooks/{webhookID:[0-9]+}/deliveries", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.WebhookDeliveriesHandler),
+	)).Methods("GET")
+	r.Handle("/api/reports", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ReportCreateHandler),
+	)).Methods("POST")
+	r.Handle("/api/moderation/reports", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ModerationReportsHandler),
+	)).Methods("GET")
+	r.Handle("/api/moderation/reports/{reportID:[0-9]+}", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ModerationReportUpdateHandler),
+	)).Methods("PUT")
+	r.Handle("/api/stream", negroni.New(
+		negroni.HandlerFunc(jwt.Stream.HandlerWithNext),
+		negroni.WrapFunc(app.StreamHandler),
+	)).Methods("GET")
+	r.Handle("/api/lists/{shareToken}", negroni.New(
+		negroni.HandlerFunc(jwt.Optional.HandlerWithNext),
+		negroni.WrapFunc(app.SharedReadingListHandler),
+	)).Methods("GET")
+	r.Handle("/api/user/feed-token", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.GetFeedTokenHandler),
+	)).Methods("GET")
+	r.Handle("/api/user/feed-token", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ResetFeedTokenHandler),
+	)).Methods("POST")
+
+	r.Handle("/api/uploads/avatar", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.AvatarUploadHandler),
+	)).Methods("POST")
+	r.Handle("/api/uploads/images", negroni.New(
+		negroni.HandlerFunc(jwt.Required.HandlerWithNext),
+		negroni.WrapFunc(app.ImageUploadHandler),
+	)).Methods("POST")
+	if local, ok := store.(*storage.LocalStore); ok && strings.HasPrefix(local.BaseURL, "/") {
+		r.PathPrefix(local.BaseURL).Handler(
+			http.StripPrefix(local.BaseURL, local.Handler())).Methods("GET")
+	}
+
+	r.HandleFunc("/feeds/articles.atom", app.GlobalAtomHandler).Methods("GET")
+	r.HandleFunc("/feeds/tags/{tag}.rss", app.TagRSSHandler).Methods("GET")
+	r.HandleFunc("/feeds/authors/{username}.atom", app.AuthorAtomHandler).Methods("GET")
+	r.HandleFunc("/feeds/users/{token}.atom", app.PrivateAtomHandler).Methods("GET")
 
 	http.Handle("/", r)
-	http.ListenAndServe(viper.GetString("GO_PORT"), nil)
+	srv := &http.Server{Addr: cfg.Port}
+	// Streams never finish on their own; end them so Shutdown can complete.
+	srv.RegisterOnShutdown(func() { hub.Close() })
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			panic(fmt.Errorf("Fatal listen: %s \n", err))
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	// Flip /readyz first and give the orchestrator a moment to notice
+	// before we stop accepting connections.
+	app.SetShuttingDown()
+	time.Sleep(5 * time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	srv.Shutdown(ctx)
 }
@@ -0,0 +1,90 @@
+package markdown
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var frontMatterDelimiter = []byte("---")
+
+var ErrNoFrontMatter = errors.New("missing front matter")
+
+// FrontMatter is the YAML header of a Markdown article, delimited by "---"
+// lines.
+type FrontMatter struct {
+	Title       string   `yaml:"title"`
+	Description string   `yaml:"description,omitempty"`
+	Tags        []string `yaml:"tags,omitempty"`
+	Date        string   `yaml:"date,omitempty"`
+	Slug        string   `yaml:"slug,omitempty"`
+}
+
+// dateLayouts are the date formats accepted in front matter.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Time parses Date, returning the zero time when it is empty.
+func (fm *FrontMatter) Time() (time.Time, error) {
+	if fm.Date == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, fm.Date); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", fm.Date)
+}
+
+// ParseFrontMatter splits a Markdown document into its front matter and body.
+func ParseFrontMatter(src []byte) (*FrontMatter, string, error) {
+	src = bytes.TrimPrefix(src, []byte("\xef\xbb\xbf"))
+	src = bytes.Replace(src, []byte("\r\n"), []byte("\n"), -1)
+
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	if len(lines) == 0 || !bytes.Equal(bytes.TrimSpace(lines[0]), frontMatterDelimiter) {
+		return nil, "", ErrNoFrontMatter
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if !bytes.Equal(bytes.TrimSpace(lines[i]), frontMatterDelimiter) {
+			continue
+		}
+
+		var fm FrontMatter
+		if err := yaml.Unmarshal(bytes.Join(lines[1:i], nil), &fm); err != nil {
+			return nil, "", err
+		}
+		body := bytes.Join(lines[i+1:], nil)
+		return &fm, string(bytes.TrimLeft(body, "\n")), nil
+	}
+	return nil, "", ErrNoFrontMatter
+}
+
+// FormatFrontMatter renders a Markdown document with front matter.
+func FormatFrontMatter(fm *FrontMatter, body string) ([]byte, error) {
+	header, err := yaml.Marshal(fm)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(frontMatterDelimiter)
+	buf.WriteByte('\n')
+	buf.Write(header)
+	buf.Write(frontMatterDelimiter)
+	buf.WriteString("\n\n")
+	buf.WriteString(body)
+	if len(body) > 0 && body[len(body)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
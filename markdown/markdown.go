@@ -0,0 +1,68 @@
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// Heading is one entry of an article's table of contents.
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+)
+
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// Keep heading anchors so the table of contents can link to them.
+	p.AllowAttrs("id").Matching(bluemonday.SpaceSeparatedTokens).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	return p
+}
+
+// Render converts Markdown to sanitized HTML and extracts its headings.
+func Render(source string) (string, []Heading) {
+	src := []byte(source)
+	doc := md.Parser().Parse(text.NewReader(src))
+
+	var buf bytes.Buffer
+	if err := md.Renderer().Render(&buf, src, doc); err != nil {
+		return "", nil
+	}
+
+	return string(policy.SanitizeBytes(buf.Bytes())), headings(doc, src)
+}
+
+func headings(doc ast.Node, src []byte) []Heading {
+	var toc []Heading
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+
+		var id string
+		if value, ok := heading.AttributeString("id"); ok {
+			id = string(value.([]byte))
+		}
+		toc = append(toc, Heading{
+			Level: heading.Level,
+			Text:  string(heading.Text(src)),
+			ID:    id,
+		})
+		return ast.WalkSkipChildren, nil
+	})
+	return toc
+}
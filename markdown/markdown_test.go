@@ -0,0 +1,61 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderSanitizes(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		source string
+		unsafe string
+	}{
+		{"script tag", "hello\n\n<script>alert(1)</script>", "<script"},
+		{"inline script", "hello <script>alert(1)</script> world", "<script"},
+		{"javascript link", "[click](javascript:alert(1))", "javascript:"},
+		{"javascript autolink", "<javascript:alert(1)>", `href="javascript:`},
+		{"event handler", `<img src="x.png" onerror="alert(1)">`, "onerror"},
+		{"inline event handler", `text <a href="/x" onclick="alert(1)">x</a>`, "onclick"},
+		{"raw html block", "<div style=\"position:fixed\">overlay</div>", "<div"},
+		{"iframe", `<iframe src="https://example.com"></iframe>`, "<iframe"},
+	} {
+		html, _ := Render(test.source)
+		if strings.Contains(strings.ToLower(html), test.unsafe) {
+			t.Errorf("%s: Render(%q) = %q, contains %q", test.name, test.source, html, test.unsafe)
+		}
+	}
+}
+
+func TestRenderKeepsHeadings(t *testing.T) {
+	html, toc := Render("# Getting started\n\ntext\n\n## Install it\n\n### Install it\n")
+
+	for _, id := range []string{`id="getting-started"`, `id="install-it"`, `id="install-it-1"`} {
+		if !strings.Contains(html, id) {
+			t.Errorf("Render() = %q, missing %s", html, id)
+		}
+	}
+
+	want := []Heading{
+		{Level: 1, Text: "Getting started", ID: "getting-started"},
+		{Level: 2, Text: "Install it", ID: "install-it"},
+		{Level: 3, Text: "Install it", ID: "install-it-1"},
+	}
+	if !reflect.DeepEqual(toc, want) {
+		t.Errorf("toc = %+v, want %+v", toc, want)
+	}
+}
+
+func TestRenderKeepsSafeMarkup(t *testing.T) {
+	for source, want := range map[string]string{
+		"[docs](https://example.com/docs)": `href="https://example.com/docs"`,
+		"**bold**":                         "<strong>bold</strong>",
+		"~~gone~~":                         "<del>gone</del>",
+		"| a |\n|---|\n| b |":              "<table>",
+	} {
+		if html, _ := Render(source); !strings.Contains(html, want) {
+			t.Errorf("Render(%q) = %q, want it to contain %s", source, html, want)
+		}
+	}
+}
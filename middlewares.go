@@ -7,7 +7,8 @@ import (
 
 	"github.com/auth0/go-jwt-middleware"
 	jwt "github.com/dgrijalva/jwt-go"
-	"github.com/spf13/viper"
+
+	"github.com/koyoyo/realworld-starter-kit/config"
 )
 
 func customFromAuthHeader(r *http.Request) (string, error) {
@@ -24,19 +25,47 @@ func customFromAuthHeader(r *http.Request) (string, error) {
 	return authHeaderParts[1], nil
 }
 
-var JwtRequiredMiddleware = jwtmiddleware.New(jwtmiddleware.Options{
-	ValidationKeyGetter: func(token *jwt.Token) (interface{}, error) {
-		return []byte(viper.GetString("JWT_SIGNED_KEY")), nil
-	},
-	SigningMethod: jwt.SigningMethodHS256,
-	Extractor:     customFromAuthHeader,
-})
-
-var JwtOptionalMiddleware = jwtmiddleware.New(jwtmiddleware.Options{
-	ValidationKeyGetter: func(token *jwt.Token) (interface{}, error) {
-		return []byte(viper.GetString("JWT_SIGNED_KEY")), nil
-	},
-	SigningMethod:       jwt.SigningMethodHS256,
-	Extractor:           customFromAuthHeader,
-	CredentialsOptional: true,
-})
+// jwtMiddlewares check the tokens of API requests.
+type jwtMiddlewares struct {
+	Required *jwtmiddleware.JWTMiddleware
+	Optional *jwtmiddleware.JWTMiddleware
+	// Stream also accepts the token as ?token=, since browsers' EventSource
+	// can't send an Authorization header.
+	Stream *jwtmiddleware.JWTMiddleware
+}
+
+// newJwtMiddlewares accepts tokens signed with cfg.JWTSignedKey. revoked
+// reports whether the token of userID issued at issuedAt (a Unix time) was
+// revoked.
+func newJwtMiddlewares(cfg *config.Config, revoked func(userID uint, issuedAt int64) bool) *jwtMiddlewares {
+	key := []byte(cfg.JWTSignedKey)
+	validationKey := func(token *jwt.Token) (interface{}, error) {
+		if claims, ok := token.Claims.(jwt.MapClaims); ok {
+			userID, _ := claims["UserID"].(float64)
+			issuedAt, _ := claims["iat"].(float64)
+			if revoked(uint(userID), int64(issuedAt)) {
+				return nil, errors.New("Token has been revoked")
+			}
+		}
+		return key, nil
+	}
+
+	return &jwtMiddlewares{
+		Required: jwtmiddleware.New(jwtmiddleware.Options{
+			ValidationKeyGetter: validationKey,
+			SigningMethod:       jwt.SigningMethodHS256,
+			Extractor:           customFromAuthHeader,
+		}),
+		Optional: jwtmiddleware.New(jwtmiddleware.Options{
+			ValidationKeyGetter: validationKey,
+			SigningMethod:       jwt.SigningMethodHS256,
+			Extractor:           customFromAuthHeader,
+			CredentialsOptional: true,
+		}),
+		Stream: jwtmiddleware.New(jwtmiddleware.Options{
+			ValidationKeyGetter: validationKey,
+			SigningMethod:       jwt.SigningMethodHS256,
+			Extractor:           jwtmiddleware.FromFirst(customFromAuthHeader, jwtmiddleware.FromParameter("token")),
+		}),
+	}
+}
@@ -0,0 +1,265 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Account deletion policies, selected by ACCOUNT_DELETION_POLICY.
+const (
+	// DeletionAnonymize keeps the user's articles and comments under a
+	// scrubbed placeholder account.
+	DeletionAnonymize = "anonymize"
+	// DeletionDelete removes the user's articles and comments as well.
+	DeletionDelete = "delete"
+)
+
+// Account deletion states.
+const (
+	DeletionPending = "pending"
+	DeletionRunning = "running"
+	DeletionDone    = "done"
+	DeletionFailed  = "failed"
+)
+
+// DeletedUserPrefix starts the username of anonymized accounts. It is
+// reserved so that nobody can register a name passing for one.
+const DeletedUserPrefix = "deleted-user-"
+
+// IsReservedUsername reports whether username may not be registered.
+func IsReservedUsername(username string) bool {
+	return strings.HasPrefix(strings.ToLower(username), DeletedUserPrefix)
+}
+
+// deletionLease is how long a running deletion may take before another
+// worker picks it up again.
+const deletionLease = 10 * time.Minute
+
+// AccountDeletion is the background job deleting a user's account. Token
+// lets the user poll its status once their JWTs stop working.
+type AccountDeletion struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID      uint   `gorm:"index"`
+	Token       string `gorm:"unique_index"`
+	Policy      string
+	State       string `gorm:"index"`
+	Error       string
+	CompletedAt *time.Time
+}
+
+type AccountDeletionResponse struct {
+	Token       string  `json:"token"`
+	CreatedAt   string  `json:"createdAt"`
+	Policy      string  `json:"policy"`
+	State       string  `json:"state"`
+	Error       string  `json:"error,omitempty"`
+	CompletedAt *string `json:"completedAt"`
+}
+
+type AccountDeletionResponseJson struct {
+	Deletion *AccountDeletionResponse `json:"deletion"`
+}
+
+// RequestAccountDeletion revokes the user's tokens straight away and queues
+// the deletion of the account under policy. Asking again while a deletion
+// is queued returns that deletion.
+func (db *DB) RequestAccountDeletion(user *User, policy string) *AccountDeletionResponseJson {
+	var deletion AccountDeletion
+	db.Where("user_id = ? AND state IN (?)", user.ID, []string{DeletionPending, DeletionRunning}).First(&deletion)
+	if deletion.ID != 0 {
+		return db.PrepareAccountDeletionResponse(&deletion)
+	}
+
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Errorf("Deletion token err: %s", err))
+	}
+
+	deletion = AccountDeletion{
+		UserID: user.ID,
+		Token:  hex.EncodeToString(buf),
+		Policy: policy,
+		State:  DeletionPending,
+	}
+
+	tx := db.Begin()
+	(&DB{tx}).RevokeTokens(user)
+	tx.Create(&deletion)
+	tx.Commit()
+
+	return db.PrepareAccountDeletionResponse(&deletion)
+}
+
+// IsDeletingAccount reports whether the deletion of userID's account is
+// queued or running.
+func (db *DB) IsDeletingAccount(userID uint) bool {
+	var count uint
+	db.Model(&AccountDeletion{}).
+		Where("user_id = ? AND state IN (?)", userID, []string{DeletionPending, DeletionRunning}).
+		Count(&count)
+	return count > 0
+}
+
+func (db *DB) GetAccountDeletion(token string) *AccountDeletion {
+	var deletion AccountDeletion
+	if token != "" {
+		db.Where(&AccountDeletion{Token: token}).First(&deletion)
+	}
+	return &deletion
+}
+
+// ClaimAccountDeletion marks the oldest pending deletion, or one whose
+// worker seems to have died, as running and returns it. It returns nil when
+// there is nothing to do.
+func (db *DB) ClaimAccountDeletion() *AccountDeletion {
+	var id uint
+	err := db.Raw(`UPDATE account_deletions SET state = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM account_deletions
+			WHERE state = ? OR (state = ? AND updated_at < ?)
+			ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED
+		) RETURNING id`, DeletionRunning, time.Now(),
+		DeletionPending, DeletionRunning, time.Now().Add(-deletionLease)).Row().Scan(&id)
+	if err != nil {
+		return nil
+	}
+
+	var deletion AccountDeletion
+	db.First(&deletion, id)
+	return &deletion
+}
+
+// DeleteAccount runs the deletion and records its outcome. It returns the
+// blob keys of the user's uploads for the caller to remove from the store.
+func (db *DB) DeleteAccount(deletion *AccountDeletion) (blobKeys []string, err error) {
+	db, span := db.startSpan("models.DeleteAccount")
+	defer span.End()
+
+	tx := db.Begin()
+	blobKeys, err = deleteAccount(tx, deletion.UserID, deletion.Policy)
+	if err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+
+	now := time.Now()
+	deletion.CompletedAt = &now
+	deletion.State = DeletionDone
+	deletion.Error = ""
+	if err != nil {
+		deletion.State = DeletionFailed
+		deletion.Error = err.Error()
+		blobKeys = nil
+	}
+	db.Save(deletion)
+	return
+}
+
+func deleteAccount(tx *gorm.DB, userID uint, policy string) ([]string, error) {
+	if policy != DeletionAnonymize && policy != DeletionDelete {
+		return nil, fmt.Errorf("Unknown account deletion policy: %s", policy)
+	}
+
+	var assets []*Asset
+	tx.Where(&Asset{OwnerID: userID}).Find(&assets)
+	var blobKeys []string
+	for _, asset := range assets {
+		blobKeys = append(blobKeys, asset.Key)
+		variants := map[string]string{}
+		json.Unmarshal([]byte(asset.Variants), &variants)
+		for _, key := range variants {
+			blobKeys = append(blobKeys, key)
+		}
+	}
+
+	statements := []string{
+		"UPDATE articles SET favorites_count = favorites_count - 1 WHERE id IN (SELECT article_id FROM article_favorites WHERE user_id = ?)",
+		"DELETE FROM article_favorites WHERE user_id = ?",
+		"DELETE FROM followers WHERE follower_id = ? OR following_id = ?",
+		"DELETE FROM tag_follows WHERE user_id = ?",
+		"DELETE FROM bookmarks WHERE user_id = ?",
+		"DELETE FROM reading_list_items WHERE reading_list_id IN (SELECT id FROM reading_lists WHERE owner_id = ?)",
+		"DELETE FROM reading_lists WHERE owner_id = ?",
+		"DELETE FROM notification_actors WHERE notification_id IN (SELECT id FROM notifications WHERE user_id = ?)",
+		"DELETE FROM notifications WHERE user_id = ?",
+		"DELETE FROM notification_preferences WHERE user_id = ?",
+		"DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM webhooks WHERE owner_id = ?)",
+		"DELETE FROM webhooks WHERE owner_id = ?",
+		"DELETE FROM blocks WHERE blocker_id = ? OR blocked_id = ?",
+		"DELETE FROM mutes WHERE muter_id = ? OR muted_id = ?",
+		"DELETE FROM reports WHERE reporter_id = ?",
+		"DELETE FROM recommendations WHERE user_id = ?",
+		"DELETE FROM assets WHERE owner_id = ?",
+	}
+	if policy == DeletionDelete {
+		statements = append(statements,
+			"DELETE FROM notification_actors WHERE actor_id = ?",
+			"DELETE FROM notifications WHERE actor_id = ?",
+			`UPDATE articles SET comments_count = comments_count - counts.total
+				FROM (
+					SELECT article_id, COUNT(*) AS total FROM article_comments
+					WHERE author_id = ? AND deleted_at IS NULL GROUP BY article_id
+				) AS counts
+				WHERE articles.id = counts.article_id`,
+			"DELETE FROM article_comments WHERE author_id = ?",
+		)
+	}
+	for _, statement := range statements {
+		args := make([]interface{}, strings.Count(statement, "?"))
+		for i := range args {
+			args[i] = userID
+		}
+		if err := exec(tx, statement, args...).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if policy == DeletionDelete {
+		var articleIDs []uint
+		tx.Unscoped().Model(&Article{}).Where("author_id = ?", userID).Pluck("id", &articleIDs)
+		if _, err := purgeArticles(tx, articleIDs); err != nil {
+			return nil, err
+		}
+		return blobKeys, tx.Unscoped().Delete(&User{}, userID).Error
+	}
+
+	placeholder := fmt.Sprintf("%s%d", DeletedUserPrefix, userID)
+	return blobKeys, tx.Model(&User{}).Where("id = ?", userID).UpdateColumns(map[string]interface{}{
+		"username":   placeholder,
+		"email":      placeholder + "@invalid",
+		"password":   "",
+		"bio":        "",
+		"image":      nil,
+		"feed_token": nil,
+		"admin":      false,
+	}).Error
+}
+
+func (db *DB) PrepareAccountDeletionResponse(deletion *AccountDeletion) *AccountDeletionResponseJson {
+	var completedAt *string
+	if deletion.CompletedAt != nil {
+		formatted := deletion.CompletedAt.UTC().Format("2006-01-02T15:04:05.000Z")
+		completedAt = &formatted
+	}
+
+	return &AccountDeletionResponseJson{
+		Deletion: &AccountDeletionResponse{
+			Token:       deletion.Token,
+			CreatedAt:   deletion.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+			Policy:      deletion.Policy,
+			State:       deletion.State,
+			Error:       deletion.Error,
+			CompletedAt: completedAt,
+		},
+	}
+}
@@ -1,11 +1,16 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/url"
-	"strconv"
 	"time"
 
 	"github.com/gosimple/slug"
+	"github.com/jinzhu/gorm"
+
+	"github.com/koyoyo/realworld-starter-kit/events"
+	"github.com/koyoyo/realworld-starter-kit/markdown"
 )
 
 type Article struct {
@@ -14,14 +19,19 @@ type Article struct {
 	UpdatedAt time.Time  `json:"updatedAt"`
 	DeletedAt *time.Time `json:"-" sql:"index"`
 
-	Slug           string `json:"slug"`
-	Title          string `json:"title"`
-	Description    string `json:"description"`
-	Body           string `json:"body"`
-	Tag            []Tag  `gorm:"many2many:article_tags;" json:"tagList"`
-	Favorited      bool   `gorm:"-" json:"favorited"`
-	FavoritesCount uint   `json:"favoritesCount"`
-	Author         User   `json:"author"`
+	Slug           string  `json:"slug"`
+	Title          string  `json:"title"`
+	Description    string  `json:"description"`
+	Body           string  `json:"body"`
+	BodyHTML       string  `gorm:"type:text" json:"-"`
+	Toc            string  `gorm:"type:text" json:"-"`
+	Tag            []Tag   `gorm:"many2many:article_tags;" json:"tagList"`
+	Favorited      bool    `gorm:"-" json:"favorited"`
+	FavoritesCount uint    `json:"favoritesCount" sql:"index"`
+	CommentsCount  uint    `json:"-" sql:"index"`
+	TrendingScore  float64 `json:"-" sql:"index"`
+	Hidden         bool    `json:"-" sql:"not null;default:false"`
+	Author         User    `json:"author"`
 	AuthorID       uint
 }
 
@@ -47,31 +57,32 @@ type ArticleComment struct {
 	ID        uint `gorm:"primary_key"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	DeletedAt *time.Time `sql:"index"`
 
 	AuthorID  uint
 	Author    User
 	ArticleID uint
 	Article   Article
 	Body      string `json:"body"`
-}
-
-type Tag struct {
-	ID   uint   `json:"-" gorm:"primary_key"`
-	Name string `json:"name"`
+	BodyHTML  string `gorm:"type:text" json:"-"`
+	Hidden    bool   `json:"-" sql:"not null;default:false"`
 }
 
 type ArticleResponse struct {
-	ID             uint     `json:"-"`
-	CreatedAt      string   `json:"createdAt"`
-	UpdatedAt      string   `json:"updatedAt"`
-	Slug           string   `json:"slug"`
-	Title          string   `json:"title"`
-	Description    string   `json:"description"`
-	Body           string   `json:"body"`
-	Tag            []string `json:"tagList"`
-	Favorited      bool     `json:"favorited"`
-	FavoritesCount uint     `json:"favoritesCount"`
-	Author         *Author  `json:"author"`
+	ID             uint               `json:"-"`
+	CreatedAt      string             `json:"createdAt"`
+	UpdatedAt      string             `json:"updatedAt"`
+	Slug           string             `json:"slug"`
+	Title          string             `json:"title"`
+	Description    string             `json:"description"`
+	Body           string             `json:"body"`
+	BodyHTML       string             `json:"bodyHtml,omitempty"`
+	Toc            []markdown.Heading `json:"toc,omitempty"`
+	Tag            []string           `json:"tagList"`
+	Favorited      bool               `json:"favorited"`
+	FavoritesCount uint               `json:"favoritesCount"`
+	Bookmarked     bool               `json:"bookmarked"`
+	Author         *Author            `json:"author"`
 }
 
 type ArticleResponseJson struct {
@@ -88,6 +99,7 @@ type CommentResponse struct {
 	CreatedAt string  `json:"createdAt"`
 	UpdatedAt string  `json:"updatedAt"`
 	Body      string  `json:"body"`
+	BodyHTML  string  `json:"bodyHtml,omitempty"`
 	Author    *Author `json:"author"`
 }
 
@@ -99,39 +111,105 @@ type CommentsResponseJson struct {
 	Comments []*CommentResponse `json:"comments"`
 }
 
-type TagResponse struct {
-	Tags []string `json:"tags"`
-}
-
-func (db *DB) CreateArticle(title, description, body string, tagList []string, userID uint) *ArticleResponseJson {
+// CreateArticle slugs the title, adding a numeric suffix when another
+// article, even a deleted one, already uses the slug.
+func (db *DB) CreateArticle(title, description, body string, tagList []string,
+	userID uint) (*ArticleResponseJson, error) {
 	article := Article{
 		Title:       title,
-		Slug:        slug.Make(title),
 		Description: description,
 		Body:        body,
 		AuthorID:    userID,
 	}
-
-	var tags []Tag
-	for _, tagName := range tagList {
-		var tag Tag
-		db.Where(Tag{Name: tagName}).FirstOrCreate(&tag)
-		tags = append(tags, tag)
+	article.renderBody()
+
+	tx := db.Begin()
+	article.Slug = uniqueSlug(tx, slug.Make(title))
+	article.Tag = findOrCreateTags(tx, tagList)
+	if err := tx.Create(&article).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := events.Record(tx, &events.ArticleCreated{ArticleID: article.ID, AuthorID: userID}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
 	}
-	article.Tag = tags
-
-	db.Create(&article)
 
 	var author User
 	db.First(&author, userID)
 	article.Author = author
-	return db.PrepareArticleResponse(&article)
+	return db.PrepareArticleResponse(&article), nil
+}
+
+// ImportArticle creates an article brought over from elsewhere, keeping its
+// slug unless another article already uses it, in which case a numeric
+// suffix is added. A non-zero date becomes the article's creation time.
+func (db *DB) ImportArticle(authorID uint, title, description, body string, tagList []string,
+	articleSlug string, date time.Time) (*Article, error) {
+	if articleSlug == "" {
+		articleSlug = title
+	}
+
+	article := Article{
+		Title:       title,
+		Description: description,
+		Body:        body,
+		AuthorID:    authorID,
+		CreatedAt:   date,
+		UpdatedAt:   date,
+	}
+	article.renderBody()
+
+	tx := db.Begin()
+	article.Slug = uniqueSlug(tx, slug.Make(articleSlug))
+	article.Tag = findOrCreateTags(tx, tagList)
+	if err := tx.Create(&article).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	events.Record(tx, &events.ArticleCreated{ArticleID: article.ID, AuthorID: authorID})
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &article, nil
+}
+
+// uniqueSlug returns base, or base with the first free numeric suffix when
+// an article, even a deleted one, already uses it.
+func uniqueSlug(tx *gorm.DB, base string) string {
+	candidate := base
+	for i := 2; ; i++ {
+		var count uint
+		tx.Unscoped().Model(&Article{}).Where("slug = ?", candidate).Count(&count)
+		if count == 0 {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
 }
 
-func (db *DB) UpdateArticle(article *Article, title, description, body string) *ArticleResponseJson {
-	if title != "" {
+// ListAuthorArticles returns every live article of authorID, oldest first.
+func (db *DB) ListAuthorArticles(authorID uint) []*Article {
+	var articles []*Article
+	db.Preload("Tag").Where("author_id = ?", authorID).Order("id").Find(&articles)
+	return articles
+}
+
+// UpdateArticle changes the non-empty fields. A new title gets a new slug,
+// made unique like CreateArticle's. A nil tagList leaves the tags alone while
+// an empty one removes them all.
+func (db *DB) UpdateArticle(article *Article, title, description, body string,
+	tagList []string) (*ArticleResponseJson, error) {
+	tx := db.Begin()
+	if title != "" && title != article.Title {
 		article.Title = title
-		article.Slug = slug.Make(title)
+		if titleSlug := slug.Make(title); titleSlug != article.Slug {
+			article.Slug = uniqueSlug(tx, titleSlug)
+		}
 	}
 
 	if description != "" {
@@ -140,21 +218,104 @@ func (db *DB) UpdateArticle(article *Article, title, description, body string) *
 
 	if body != "" {
 		article.Body = body
+		article.renderBody()
+	}
+	if tagList != nil {
+		article.Tag = findOrCreateTags(tx, tagList)
+		if err := tx.Model(article).Association("Tag").Replace(article.Tag).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := tx.Save(&article).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	deleteOrphanTags(tx)
+	if err := events.Record(tx, &events.ArticleUpdated{ArticleID: article.ID, AuthorID: article.AuthorID}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return db.PrepareArticleResponse(article), nil
+}
+
+// renderBody caches the sanitized HTML and table of contents of Body.
+func (article *Article) renderBody() {
+	html, toc := markdown.Render(article.Body)
+	article.BodyHTML = html
+
+	article.Toc = ""
+	if len(toc) > 0 {
+		if encoded, err := json.Marshal(toc); err == nil {
+			article.Toc = string(encoded)
+		}
+	}
+}
+
+// DeleteArticle moves the article to its author's trash along with its
+// comments. Favorites, bookmarks and tags are kept until the trash is purged
+// so that RestoreArticle can bring everything back.
+func (db *DB) DeleteArticle(article *Article) (err error) {
+	deletedAt := gorm.NowFunc()
+
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = tx.Model(&Article{}).Where("id = ?", article.ID).UpdateColumn("deleted_at", deletedAt).Error; err != nil {
+		return
+	}
+	err = tx.Model(&ArticleComment{}).Where("article_id = ?", article.ID).UpdateColumn("deleted_at", deletedAt).Error
+	if err != nil {
+		return
+	}
+	err = events.Record(tx, &events.ArticleDeleted{
+		ArticleID: article.ID,
+		AuthorID:  article.AuthorID,
+		Slug:      article.Slug,
+		Title:     article.Title,
+	})
+	if err != nil {
+		return
 	}
-	db.Save(&article)
 
-	return db.PrepareArticleResponse(article)
+	err = tx.Commit().Error
+	return
 }
 
-func (db *DB) DeleteArticle(article *Article) {
-	db.Delete(&article)
+// articleSorts maps the ?sort= values of article listings to their ORDER BY.
+// Each one is backed by an index on articles.
+var articleSorts = map[string]string{
+	"newest":         "articles.id desc",
+	"oldest":         "articles.id asc",
+	"most-favorited": "articles.favorites_count desc, articles.id desc",
+	"most-commented": "articles.comments_count desc, articles.id desc",
+	"trending":       "articles.trending_score desc, articles.id desc",
 }
 
-func (db *DB) listArticle(queries url.Values) (articles []*Article, count uint) {
-	sql := db.Preload("Tag").Preload("Author").Order("ID desc")
+// articleOrder returns the ORDER BY for ?sort=, defaulting to newest.
+func articleOrder(queries url.Values) string {
+	if order, ok := articleSorts[queries.Get("sort")]; ok {
+		return order
+	}
+	return articleSorts["newest"]
+}
+
+// listArticle lists the articles matching queries, leaving out the authors
+// viewerID muted or blocked.
+func (db *DB) listArticle(queries url.Values, viewerID uint) (articles []*Article, count uint) {
+	sql := db.Preload("Tag").Preload("Author").Where("articles.hidden = ?", false)
+	sql = withoutSilenced(sql, "articles.author_id", viewerID)
 
 	if tagQuery, ok := queries["tag"]; ok {
-		tag := tagQuery[0]
+		tag := NormalizeTag(tagQuery[0])
 
 		sql = sql.Joins("JOIN article_tags ON article_tags.article_id=articles.id").
 			Joins("JOIN tags ON article_tags.tag_id=tags.id").
@@ -180,60 +341,71 @@ func (db *DB) listArticle(queries url.Values) (articles []*Article, count uint)
 		}
 	}
 
-	limit := 20
-	if limitStr, ok := queries["limit"]; ok {
-		if limitTmp, err := strconv.Atoi(limitStr[0]); err == nil {
-			limit = limitTmp
-		}
-	}
-	var offset int
-	if offsetStr, ok := queries["offset"]; ok {
-		if offsetTmp, err := strconv.Atoi(offsetStr[0]); err == nil {
-			offset = offsetTmp
-		}
-	}
+	limit, offset := pagination(queries)
 
 	sql.Model(&Article{}).Count(&count)
-	sql.Offset(offset).Limit(limit).Find(&articles)
+	sql.Order(articleOrder(queries)).Offset(offset).Limit(limit).Find(&articles)
 	return
 }
 
 func (db *DB) ListArticle(queries url.Values) *ArticlesResponseJson {
-	articles, count := db.listArticle(queries)
+	articles, count := db.listArticle(queries, 0)
 	return db.PrepareArticlesResponse(articles, count)
 }
 
 func (db *DB) ListArticleWithUser(queries url.Values, userID uint) *ArticlesResponseJson {
-	articles, count := db.listArticle(queries)
+	articles, count := db.listArticle(queries, userID)
 	return db.PrepareArticlesResponseWithUser(articles, count, userID)
 }
 
-func (db *DB) ListArticleFeed(queries url.Values, userID uint) *ArticlesResponseJson {
-	var ids []uint
-	db.Find(&Follower{FollowingID: userID}).Pluck("follower_id", &ids)
-
-	sql := db.Where("author_id in (?)", ids).Preload("Tag").Preload("Author").Order("ID desc")
-
-	limit := 20
-	if limitStr, ok := queries["limit"]; ok {
-		if limitTmp, err := strconv.Atoi(limitStr[0]); err == nil {
-			limit = limitTmp
-		}
-	}
-	var offset int
-	if offsetStr, ok := queries["offset"]; ok {
-		if offsetTmp, err := strconv.Atoi(offsetStr[0]); err == nil {
-			offset = offsetTmp
-		}
+// listArticleFeed merges the articles of followed authors with those carrying
+// a followed tag. ?source=authors or ?source=tags restricts it to one of them.
+func (db *DB) listArticleFeed(queries url.Values, userID uint) (articles []*Article, count uint) {
+	byAuthors := db.Model(&Follower{}).Select("following_id").Where(&Follower{FollowerID: userID}).QueryExpr()
+	byTags := db.Table("article_tags").Select("article_tags.article_id").
+		Joins("JOIN tag_follows ON tag_follows.tag_id = article_tags.tag_id").
+		Where("tag_follows.user_id = ?", userID).QueryExpr()
+
+	sql := db.Preload("Tag").Preload("Author").Where("articles.hidden = ?", false)
+	sql = withoutSilenced(sql, "articles.author_id", userID)
+	switch queries.Get("source") {
+	case "authors":
+		sql = sql.Where("author_id IN (?)", byAuthors)
+	case "tags":
+		sql = sql.Where("id IN (?)", byTags)
+	default:
+		sql = sql.Where("author_id IN (?) OR id IN (?)", byAuthors, byTags)
 	}
 
-	var count uint
-	var articles []*Article
+	limit, offset := pagination(queries)
+
 	sql.Model(&Article{}).Count(&count)
-	sql.Offset(offset).Limit(limit).Find(&articles)
+	sql.Order(articleOrder(queries)).Offset(offset).Limit(limit).Find(&articles)
+	return
+}
+
+func (db *DB) ListArticleFeed(queries url.Values, userID uint) *ArticlesResponseJson {
+	db, span := db.startSpan("models.ListArticleFeed")
+	defer span.End()
+
+	articles, count := db.listArticleFeed(queries, userID)
 	return db.PrepareArticlesResponseWithUser(articles, count, userID)
 }
 
+// SyndicationArticles returns the articles behind ListArticle for building
+// RSS and Atom feeds.
+func (db *DB) SyndicationArticles(queries url.Values) []*Article {
+	articles, _ := db.listArticle(queries, 0)
+	return articles
+}
+
+// SyndicationArticleFeed returns the articles behind ListArticleFeed for
+// building a user's private RSS and Atom feeds.
+func (db *DB) SyndicationArticleFeed(queries url.Values, userID uint) []*Article {
+	articles, _ := db.listArticleFeed(queries, userID)
+	return articles
+}
+
 func (db *DB) CountArticle() uint {
 	var count uint
 	db.Model(&Article{}).Count(&count)
@@ -246,9 +418,10 @@ func (db *DB) GetArticleFromSlug(slug string) *Article {
 	return &article
 }
 
+// GetArticleResponseFromSlug leaves out articles hidden by moderation.
 func (db *DB) GetArticleResponseFromSlug(slug string) *ArticleResponseJson {
 	var article Article
-	db.Preload("Tag").Preload("Author").Where(Article{Slug: slug}).First(&article)
+	db.Preload("Tag").Preload("Author").Where(Article{Slug: slug}).Where("hidden = ?", false).First(&article)
 	return db.PrepareArticleResponse(&article)
 }
 
@@ -271,11 +444,24 @@ func (db *DB) PrepareArticlesResponse(articles []*Article, count uint) *Articles
 }
 
 func (db *DB) PrepareArticlesResponseWithUser(articles []*Article, count uint, userID uint) *ArticlesResponseJson {
+	db, span := db.startSpan("models.PrepareArticlesResponseWithUser")
+	defer span.End()
+
+	var articleIDs, authorIDs []uint
+	for _, article := range articles {
+		articleIDs = append(articleIDs, article.ID)
+		authorIDs = append(authorIDs, article.AuthorID)
+	}
+	favorited := db.favoritedArticleIDs(articleIDs, userID)
+	bookmarked := db.bookmarkedArticleIDs(articleIDs, userID)
+	following := db.followingUserIDs(userID, authorIDs)
+
 	var articlesResponse []*ArticleResponse
 	for _, article := range articles {
 		article := db.PrepareArticle(article)
-		article.Favorited = db.IsFavorite(article.ID, userID)
-		article.Author.Following = db.IsFollowing(article.Author.ID, userID)
+		article.Favorited = favorited[article.ID]
+		article.Bookmarked = bookmarked[article.ID]
+		article.Author.Following = following[article.Author.ID]
 
 		articlesResponse = append(articlesResponse, article)
 	}
@@ -292,6 +478,15 @@ func (db *DB) PrepareArticle(article *Article) *ArticleResponse {
 		tags = append(tags, tag.Name)
 	}
 
+	bodyHTML := article.BodyHTML
+	var toc []markdown.Heading
+	if bodyHTML == "" && article.Body != "" {
+		// Rows written before bodies were rendered on save.
+		bodyHTML, toc = markdown.Render(article.Body)
+	} else if article.Toc != "" {
+		json.Unmarshal([]byte(article.Toc), &toc)
+	}
+
 	return &ArticleResponse{
 		ID:          article.ID,
 		CreatedAt:   article.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
@@ -300,6 +495,8 @@ func (db *DB) PrepareArticle(article *Article) *ArticleResponse {
 		Title:       article.Title,
 		Description: article.Description,
 		Body:        article.Body,
+		BodyHTML:    bodyHTML,
+		Toc:         toc,
 		Tag:         tags,
 		// Favorited: article.Favorited,
 		FavoritesCount: article.FavoritesCount,
@@ -313,71 +510,270 @@ func (db *DB) PrepareArticle(article *Article) *ArticleResponse {
 	}
 }
 
-func (db *DB) ListTags() *TagResponse {
-	var tags []string
-	db.Model(&Tag{}).Pluck("name", &tags)
-	return &TagResponse{
-		Tags: tags,
-	}
-}
-
 func (db *DB) IsFavorite(articleID, userID uint) bool {
 	var count uint
 	db.Model(&ArticleFavorite{}).Where(&ArticleFavorite{UserID: userID, ArticleID: articleID}).Count(&count)
 	return count > 0
 }
 
-func (db *DB) FavoriteArticle(articleID, userID uint) (isAlreadyFav bool) {
-	var articleFav ArticleFavorite
-	if results := db.FirstOrCreate(&articleFav, ArticleFavorite{UserID: userID, ArticleID: articleID}); results.RowsAffected == 0 {
-		isAlreadyFav = true
+// favoritedArticleIDs resolves IsFavorite for a whole page of articles in a
+// single query.
+func (db *DB) favoritedArticleIDs(articleIDs []uint, userID uint) map[uint]bool {
+	favorited := map[uint]bool{}
+	if len(articleIDs) == 0 {
+		return favorited
+	}
+
+	var ids []uint
+	db.Model(&ArticleFavorite{}).Where("user_id = ? AND article_id IN (?)", userID, articleIDs).
+		Pluck("article_id", &ids)
+	for _, id := range ids {
+		favorited[id] = true
+	}
+	return favorited
+}
+
+// FavoriteArticle records the favorite and bumps the article's counter in one
+// transaction, returning the counter's new value.
+func (db *DB) FavoriteArticle(articleID, userID uint) (favoritesCount uint, err error) {
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Create would scan the RETURNING id of a conflicting insert and fail
+	// with sql.ErrNoRows, so insert directly and look at RowsAffected.
+	results := exec(tx, `INSERT INTO article_favorites (created_at, user_id, article_id) VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING`, gorm.NowFunc(), userID, articleID)
+	if err = results.Error; err != nil {
+		return
+	}
+
+	if results.RowsAffected == 0 {
+		err = tx.Model(&Article{}).Where("id = ?", articleID).Select("favorites_count").
+			Row().Scan(&favoritesCount)
+	} else {
+		var authorID uint
+		err = tx.Raw("UPDATE articles SET favorites_count = favorites_count + 1 WHERE id = ? RETURNING favorites_count, author_id",
+			articleID).Row().Scan(&favoritesCount, &authorID)
+		if err == nil {
+			err = events.Record(tx, &events.ArticleFavorited{
+				ArticleID:       articleID,
+				UserID:          userID,
+				ArticleAuthorID: authorID,
+			})
+		}
+	}
+	if err != nil {
 		return
 	}
 
-	var countFavorite uint
-	db.Model(&ArticleFavorite{}).Where(&ArticleFavorite{ArticleID: articleID}).Count(&countFavorite)
-	db.Model(&Article{}).Where(&Article{ID: articleID}).Update("FavoritesCount", countFavorite)
+	err = tx.Commit().Error
 	return
 }
 
-func (db *DB) UnfavoriteArticle(articleID, userID uint) (isAlreadyUnfav bool) {
-	if results := db.Delete(&ArticleFavorite{}, ArticleFavorite{UserID: userID, ArticleID: articleID}); results.RowsAffected == 0 {
-		isAlreadyUnfav = true
+// UnfavoriteArticle removes the favorite and decrements the article's counter
+// in one transaction, returning the counter's new value.
+func (db *DB) UnfavoriteArticle(articleID, userID uint) (favoritesCount uint, err error) {
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	results := tx.Where(&ArticleFavorite{UserID: userID, ArticleID: articleID}).Delete(&ArticleFavorite{})
+	if err = results.Error; err != nil {
 		return
 	}
 
-	var countFavorite uint
-	db.Model(&ArticleFavorite{}).Where(&ArticleFavorite{ArticleID: articleID}).Count(&countFavorite)
-	db.Model(&Article{}).Where(&Article{ID: articleID}).Update("FavoritesCount", countFavorite)
+	if results.RowsAffected == 0 {
+		err = tx.Model(&Article{}).Where("id = ?", articleID).Select("favorites_count").
+			Row().Scan(&favoritesCount)
+	} else {
+		var authorID uint
+		err = tx.Raw("UPDATE articles SET favorites_count = favorites_count - 1 WHERE id = ? RETURNING favorites_count, author_id",
+			articleID).Row().Scan(&favoritesCount, &authorID)
+		if err == nil {
+			err = events.Record(tx, &events.ArticleUnfavorited{
+				ArticleID:       articleID,
+				UserID:          userID,
+				ArticleAuthorID: authorID,
+			})
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit().Error
+	return
+}
+
+// commentTrendingWeight is how many favorites a comment is worth in the
+// trending score.
+const commentTrendingWeight = 2
+
+// trendingHalfLives caps how many half-lives old an interaction counts as.
+// Past about 1075, POWER(0.5, x) fails with an underflow error; capped, an
+// old interaction adds a constant and the article's score stops changing.
+const trendingHalfLives = 1000
+
+// RefreshTrendingScores recomputes TrendingScore for every article. Each
+// favorite and comment counts for half as much every halfLife. It returns
+// how many articles had their score changed; the others aren't written.
+func (db *DB) RefreshTrendingScores(halfLife time.Duration) (int64, error) {
+	seconds := halfLife.Seconds()
+	results := exec(db.DB, `UPDATE articles SET trending_score = scores.score
+		FROM (
+			SELECT articles.id, COALESCE(favorites.score, 0) + ? * COALESCE(comments.score, 0) AS score
+			FROM articles
+			LEFT JOIN (
+				SELECT article_id, SUM(POWER(0.5, LEAST(EXTRACT(EPOCH FROM now() - created_at) / ?, ?))) AS score
+				FROM article_favorites GROUP BY article_id
+			) AS favorites ON favorites.article_id = articles.id
+			LEFT JOIN (
+				SELECT article_id, SUM(POWER(0.5, LEAST(EXTRACT(EPOCH FROM now() - created_at) / ?, ?))) AS score
+				FROM article_comments WHERE deleted_at IS NULL GROUP BY article_id
+			) AS comments ON comments.article_id = articles.id
+			WHERE articles.deleted_at IS NULL
+		) AS scores
+		WHERE articles.id = scores.id AND articles.trending_score <> scores.score`,
+		commentTrendingWeight, seconds, trendingHalfLives, seconds, trendingHalfLives)
+	return results.RowsAffected, results.Error
+}
+
+// ReconcileFavoritesCount recomputes FavoritesCount from article_favorites and
+// returns how many articles had drifted.
+func (db *DB) ReconcileFavoritesCount() (int64, error) {
+	results := exec(db.DB, `UPDATE articles SET favorites_count = counts.total
+		FROM (
+			SELECT articles.id, COUNT(article_favorites.id) AS total
+			FROM articles LEFT JOIN article_favorites ON article_favorites.article_id = articles.id
+			GROUP BY articles.id
+		) AS counts
+		WHERE articles.id = counts.id AND articles.favorites_count <> counts.total`)
+	return results.RowsAffected, results.Error
+}
+
+// ReconcileCommentsCount recomputes CommentsCount from the live comments,
+// returning how many articles were off.
+func (db *DB) ReconcileCommentsCount() (int64, error) {
+	results := exec(db.DB, `UPDATE articles SET comments_count = counts.total
+		FROM (
+			SELECT articles.id, COUNT(article_comments.id) AS total
+			FROM articles LEFT JOIN article_comments
+				ON article_comments.article_id = articles.id AND article_comments.deleted_at IS NULL
+			GROUP BY articles.id
+		) AS counts
+		WHERE articles.id = counts.id AND articles.comments_count <> counts.total`)
+	return results.RowsAffected, results.Error
+}
+
+// renderBatchSize is how many rows RenderMarkdown loads at a time.
+const renderBatchSize = 500
+
+// RenderMarkdown renders the cached HTML of every article and comment again,
+// including those in the trash, after the Markdown renderer or its
+// sanitizing policy changed. UpdatedAt is left alone.
+func (db *DB) RenderMarkdown() (articles, comments int, err error) {
+	for lastID := uint(0); ; {
+		var batch []*Article
+		if err = db.Unscoped().Where("id > ?", lastID).Order("id").Limit(renderBatchSize).Find(&batch).Error; err != nil {
+			return
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, article := range batch {
+			article.renderBody()
+			if err = db.Unscoped().Model(article).UpdateColumns(map[string]interface{}{
+				"body_html": article.BodyHTML,
+				"toc":       article.Toc,
+			}).Error; err != nil {
+				return
+			}
+			lastID = article.ID
+		}
+		articles += len(batch)
+	}
+
+	for lastID := uint(0); ; {
+		var batch []*ArticleComment
+		if err = db.Unscoped().Where("id > ?", lastID).Order("id").Limit(renderBatchSize).Find(&batch).Error; err != nil {
+			return
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, comment := range batch {
+			bodyHTML, _ := markdown.Render(comment.Body)
+			if err = db.Unscoped().Model(comment).UpdateColumn("body_html", bodyHTML).Error; err != nil {
+				return
+			}
+			lastID = comment.ID
+		}
+		comments += len(batch)
+	}
 	return
 }
 
-func (db *DB) AddArticleComment(article *Article, userID uint, body string) *CommentResponseJson {
+func (db *DB) AddArticleComment(article *Article, userID uint, body string) (*CommentResponseJson, error) {
+	bodyHTML, _ := markdown.Render(body)
 	comment := &ArticleComment{
 		AuthorID:  userID,
 		ArticleID: article.ID,
 		Body:      body,
+		BodyHTML:  bodyHTML,
+	}
+	tx := db.Begin()
+	if err := tx.Create(&comment).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err := tx.Model(&Article{}).Where("id = ?", article.ID).
+		UpdateColumn("comments_count", gorm.Expr("comments_count + 1")).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = events.Record(tx, &events.CommentCreated{
+		CommentID:       comment.ID,
+		ArticleID:       article.ID,
+		AuthorID:        userID,
+		ArticleAuthorID: article.AuthorID,
+	})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
 	}
-	db.Create(&comment)
 
 	var author User
 	db.First(&author, userID)
 	comment.Author = author
-	return db.PrepareCommentResponse(comment)
+	return db.PrepareCommentResponse(comment), nil
 }
 
-func (db *DB) listArticleComment(articleID uint) (comments []*ArticleComment) {
-	db.Where(&ArticleComment{ArticleID: articleID}).Order("ID desc").Find(&comments)
+// listArticleComment lists the comments of an article, leaving out the
+// authors viewerID muted or blocked.
+func (db *DB) listArticleComment(articleID, viewerID uint) (comments []*ArticleComment) {
+	sql := db.Preload("Author").Where(&ArticleComment{ArticleID: articleID}).Where("hidden = ?", false)
+	withoutSilenced(sql, "author_id", viewerID).Order("ID desc").Find(&comments)
 	return
 }
 
 func (db *DB) ListArticleComment(articleID uint) *CommentsResponseJson {
-	comments := db.listArticleComment(articleID)
+	comments := db.listArticleComment(articleID, 0)
 	return db.PrepareCommentsResponse(comments)
 }
 
 func (db *DB) ListArticleCommentWithUser(articleID uint, userID uint) *CommentsResponseJson {
-	comments := db.listArticleComment(articleID)
+	comments := db.listArticleComment(articleID, userID)
 	return db.PrepareCommentsResponseWithUser(comments, userID)
 }
 
@@ -390,8 +786,21 @@ func (db *DB) GetArticleComment(commentID uint, articleSlug string) *ArticleComm
 	return &comment
 }
 
+// GetComment finds a live comment by id. A struct condition would drop a
+// zero id and match any comment, hence the explicit one.
+func (db *DB) GetComment(commentID uint) *ArticleComment {
+	var comment ArticleComment
+	db.Where("id = ?", commentID).First(&comment)
+	return &comment
+}
+
+// DeleteArticleComment moves the comment to its author's trash.
 func (db *DB) DeleteArticleComment(comment *ArticleComment) {
-	db.Delete(&comment)
+	tx := db.Begin()
+	tx.Delete(&comment)
+	tx.Model(&Article{}).Where("id = ?", comment.ArticleID).
+		UpdateColumn("comments_count", gorm.Expr("comments_count - 1"))
+	tx.Commit()
 }
 
 func (db *DB) PrepareCommentResponse(comment *ArticleComment) *CommentResponseJson {
@@ -412,10 +821,19 @@ func (db *DB) PrepareCommentsResponse(comments []*ArticleComment) *CommentsRespo
 }
 
 func (db *DB) PrepareCommentsResponseWithUser(comments []*ArticleComment, userID uint) *CommentsResponseJson {
+	db, span := db.startSpan("models.PrepareCommentsResponseWithUser")
+	defer span.End()
+
+	var authorIDs []uint
+	for _, comment := range comments {
+		authorIDs = append(authorIDs, comment.AuthorID)
+	}
+	following := db.followingUserIDs(userID, authorIDs)
+
 	var commentsResponse []*CommentResponse
 	for _, comment := range comments {
 		comment := db.PrepareComment(comment)
-		comment.Author.Following = db.IsFollowing(comment.Author.ID, userID)
+		comment.Author.Following = following[comment.Author.ID]
 
 		commentsResponse = append(commentsResponse, comment)
 	}
@@ -426,11 +844,17 @@ func (db *DB) PrepareCommentsResponseWithUser(comments []*ArticleComment, userID
 }
 
 func (db *DB) PrepareComment(comment *ArticleComment) *CommentResponse {
+	bodyHTML := comment.BodyHTML
+	if bodyHTML == "" && comment.Body != "" {
+		bodyHTML, _ = markdown.Render(comment.Body)
+	}
+
 	return &CommentResponse{
 		ID:        comment.ID,
 		CreatedAt: comment.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
 		UpdatedAt: comment.UpdatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
 		Body:      comment.Body,
+		BodyHTML:  bodyHTML,
 		Author: &Author{
 			ID:        comment.Author.ID,
 			Username:  comment.Author.Username,
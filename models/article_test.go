@@ -0,0 +1,54 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestFavoriteArticleConcurrently(t *testing.T) {
+	db := testDB(t)
+	author := createTestUser(t, db, "author")
+	article := createTestArticle(t, db, "Concurrent favorites", "body", nil, author.ID)
+
+	// Every user favorites the article twice at the same time, so half of
+	// the inserts conflict.
+	const users = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*users)
+	for i := 0; i < users; i++ {
+		user := createTestUser(t, db, fmt.Sprintf("fan%d", i))
+		for j := 0; j < 2; j++ {
+			wg.Add(1)
+			go func(userID uint) {
+				defer wg.Done()
+				if _, err := db.FavoriteArticle(article.ID, userID); err != nil {
+					errs <- err
+				}
+			}(user.ID)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("FavoriteArticle: %s", err)
+	}
+
+	var rows uint
+	db.Model(&ArticleFavorite{}).Where("article_id = ?", article.ID).Count(&rows)
+	var stored Article
+	db.First(&stored, article.ID)
+	if rows != users || stored.FavoritesCount != rows {
+		t.Errorf("favorites_count = %d with %d rows, want %d", stored.FavoritesCount, rows, users)
+	}
+
+	// Favoriting again is a no-op returning the current count.
+	count, err := db.FavoriteArticle(article.ID, author.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := db.FavoriteArticle(article.ID, author.ID)
+	if err != nil || again != count || count != users+1 {
+		t.Errorf("favoriting twice: got %d, %d (%v), want %d", count, again, err, users+1)
+	}
+}
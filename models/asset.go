@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AvatarAsset = "avatar"
+	ImageAsset  = "image"
+)
+
+// Asset is an uploaded file kept in the blob store. Variants maps a variant
+// name such as "thumbnail" to the blob key of the resized copy.
+type Asset struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+
+	OwnerID     uint `gorm:"index"`
+	Owner       User
+	Kind        string
+	ContentType string
+	Size        int64
+	Key         string
+	Variants    string `gorm:"type:text"`
+}
+
+type AssetResponse struct {
+	ID          uint              `json:"id"`
+	Kind        string            `json:"kind"`
+	ContentType string            `json:"contentType"`
+	Size        int64             `json:"size"`
+	URL         string            `json:"url"`
+	Variants    map[string]string `json:"variants"`
+}
+
+type AssetResponseJson struct {
+	Asset *AssetResponse `json:"asset"`
+}
+
+func (db *DB) CreateAsset(ownerID uint, kind, contentType string, size int64, key string,
+	variants map[string]string) *Asset {
+	encoded, _ := json.Marshal(variants)
+	asset := Asset{
+		OwnerID:     ownerID,
+		Kind:        kind,
+		ContentType: contentType,
+		Size:        size,
+		Key:         key,
+		Variants:    string(encoded),
+	}
+	db.Create(&asset)
+	return &asset
+}
+
+// GetAsset finds an asset uploaded by ownerID. A zero assetID finds nothing;
+// a struct condition would drop it and match any of the owner's assets.
+func (db *DB) GetAsset(assetID, ownerID uint) *Asset {
+	var asset Asset
+	db.Where("id = ? AND owner_id = ?", assetID, ownerID).First(&asset)
+	return &asset
+}
+
+// VariantKey returns the blob key of the named variant, falling back to the
+// original upload.
+func (asset *Asset) VariantKey(name string) string {
+	variants := map[string]string{}
+	json.Unmarshal([]byte(asset.Variants), &variants)
+	if key, ok := variants[name]; ok {
+		return key
+	}
+	return asset.Key
+}
+
+// PrepareAssetResponse builds the response using url to turn blob keys into
+// download URLs.
+func (db *DB) PrepareAssetResponse(asset *Asset, url func(key string) string) *AssetResponseJson {
+	variants := map[string]string{}
+	json.Unmarshal([]byte(asset.Variants), &variants)
+	for name, key := range variants {
+		variants[name] = url(key)
+	}
+
+	return &AssetResponseJson{
+		Asset: &AssetResponse{
+			ID:          asset.ID,
+			Kind:        asset.Kind,
+			ContentType: asset.ContentType,
+			Size:        asset.Size,
+			URL:         url(asset.Key),
+			Variants:    variants,
+		},
+	}
+}
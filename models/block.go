@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/koyoyo/realworld-starter-kit/events"
+)
+
+// Block stops BlockedID from following BlockerID and from commenting on or
+// favoriting BlockerID's articles. Like a Mute it also leaves BlockedID's
+// articles and comments out of BlockerID's listings.
+type Block struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	BlockerID uint `gorm:"unique_index:block"`
+	BlockedID uint `gorm:"unique_index:block"`
+}
+
+// Mute leaves MutedID's articles and comments out of MuterID's listings.
+// Unlike a Block it is invisible to MutedID.
+type Mute struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	MuterID   uint `gorm:"unique_index:mute"`
+	MutedID   uint `gorm:"unique_index:mute"`
+}
+
+// Block makes blockerID block blockedID, dropping blockedID's follow of
+// blockerID.
+func (db *DB) Block(blockerID, blockedID uint) {
+	tx := db.Begin()
+	tx.FirstOrCreate(&Block{}, Block{BlockerID: blockerID, BlockedID: blockedID})
+	results := tx.Where(&Follower{FollowerID: blockedID, FollowingID: blockerID}).Delete(Follower{})
+	if results.RowsAffected > 0 {
+		events.Record(tx, &events.UserUnfollowed{FollowerID: blockedID, FollowingID: blockerID})
+	}
+	tx.Commit()
+}
+
+func (db *DB) Unblock(blockerID, blockedID uint) {
+	db.Where(&Block{BlockerID: blockerID, BlockedID: blockedID}).Delete(Block{})
+}
+
+// IsBlocked reports whether blockerID blocks blockedID.
+func (db *DB) IsBlocked(blockerID, blockedID uint) bool {
+	var count uint
+	db.Model(&Block{}).Where(&Block{BlockerID: blockerID, BlockedID: blockedID}).Count(&count)
+	return count > 0
+}
+
+func (db *DB) Mute(muterID, mutedID uint) {
+	db.FirstOrCreate(&Mute{}, Mute{MuterID: muterID, MutedID: mutedID})
+}
+
+func (db *DB) Unmute(muterID, mutedID uint) {
+	db.Where(&Mute{MuterID: muterID, MutedID: mutedID}).Delete(Mute{})
+}
+
+// IsMuted reports whether muterID mutes mutedID.
+func (db *DB) IsMuted(muterID, mutedID uint) bool {
+	var count uint
+	db.Model(&Mute{}).Where(&Mute{MuterID: muterID, MutedID: mutedID}).Count(&count)
+	return count > 0
+}
+
+// withoutSilenced drops the rows whose authorColumn is a user viewerID muted
+// or blocked. A zero viewerID, for anonymous requests, keeps every row.
+func withoutSilenced(sql *gorm.DB, authorColumn string, viewerID uint) *gorm.DB {
+	if viewerID == 0 {
+		return sql
+	}
+	return sql.Where(authorColumn+` NOT IN (
+		SELECT muted_id FROM mutes WHERE muter_id = ?
+		UNION SELECT blocked_id FROM blocks WHERE blocker_id = ?
+	)`, viewerID, viewerID)
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+// testDB connects to TEST_POSTGRES_URL, migrates it and empties every
+// table. Tests needing a database are skipped when it isn't set.
+func testDB(t testing.TB) *DB {
+	url := os.Getenv("TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+
+	conn, err := gorm.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("connect: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	db := &DB{conn}
+	db.Migrate()
+	for _, model := range Schema {
+		table := db.NewScope(model).TableName()
+		if err := db.Exec("TRUNCATE " + table + " RESTART IDENTITY CASCADE").Error; err != nil {
+			t.Fatalf("truncate %s: %s", table, err)
+		}
+	}
+	if err := db.Exec("TRUNCATE article_tags").Error; err != nil {
+		t.Fatalf("truncate article_tags: %s", err)
+	}
+	return db
+}
+
+func createTestUser(t testing.TB, db *DB, username string) *User {
+	user := db.CreateUser(username, username+"@example.com", "password").User
+	if user.ID == 0 {
+		t.Fatalf("create user %s", username)
+	}
+	return &user
+}
+
+func createTestArticle(t testing.TB, db *DB, title, body string, tagList []string, authorID uint) *Article {
+	response, err := db.CreateArticle(title, "", body, tagList, authorID)
+	if err != nil {
+		t.Fatalf("create article %q: %s", title, err)
+	}
+	return db.GetArticleFromSlug(response.Article.Slug)
+}
+
+func createTestComment(t testing.TB, db *DB, article *Article, authorID uint, body string) *CommentResponse {
+	response, err := db.AddArticleComment(article, authorID, body)
+	if err != nil {
+		t.Fatalf("comment on %s: %s", article.Slug, err)
+	}
+	return response.Comment
+}
+
+// queryCounter counts the statements run through a DB returned by
+// countQueries.
+type queryCounter struct {
+	queries int
+}
+
+func (c *queryCounter) Print(v ...interface{}) {
+	if len(v) > 0 && v[0] == "sql" {
+		c.queries++
+	}
+}
+
+func countQueries(db *DB) (*DB, *queryCounter) {
+	counter := &queryCounter{}
+	conn := db.New()
+	conn.LogMode(true)
+	conn.SetLogger(counter)
+	return &DB{conn}, counter
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// UserExport holds everything stored about a user, for data export requests.
+type UserExport struct {
+	Profile   *ExportedProfile     `json:"profile"`
+	Articles  []*ExportedArticle   `json:"articles"`
+	Comments  []*ExportedComment   `json:"comments"`
+	Favorites []*ExportedReference `json:"favorites"`
+	Bookmarks []*ExportedReference `json:"bookmarks"`
+	Following []*ExportedReference `json:"following"`
+	Followers []*ExportedReference `json:"followers"`
+}
+
+type ExportedProfile struct {
+	Username  string  `json:"username"`
+	Email     string  `json:"email"`
+	Bio       string  `json:"bio"`
+	Image     *string `json:"image"`
+	CreatedAt string  `json:"createdAt"`
+	UpdatedAt string  `json:"updatedAt"`
+}
+
+// ExportedArticle also covers articles in the trash, which have DeletedAt.
+type ExportedArticle struct {
+	*ArticleResponse
+	DeletedAt *string `json:"deletedAt"`
+}
+
+type ExportedComment struct {
+	*CommentResponse
+	ArticleSlug string  `json:"articleSlug"`
+	DeletedAt   *string `json:"deletedAt"`
+}
+
+// ExportedReference names an article by slug or a user by username.
+type ExportedReference struct {
+	Name      string `json:"name"`
+	CreatedAt string `json:"createdAt"`
+}
+
+func exportTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	formatted := t.UTC().Format("2006-01-02T15:04:05.000Z")
+	return &formatted
+}
+
+// ExportUser gathers the profile, articles, comments, favorites, bookmarks
+// and follows of userID.
+func (db *DB) ExportUser(userID uint) *UserExport {
+	var user User
+	db.First(&user, userID)
+
+	export := &UserExport{
+		Profile: &ExportedProfile{
+			Username:  user.Username,
+			Email:     user.Email,
+			Bio:       user.Bio,
+			Image:     user.Image,
+			CreatedAt: user.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+			UpdatedAt: user.UpdatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		},
+		Articles: []*ExportedArticle{},
+		Comments: []*ExportedComment{},
+	}
+
+	var articles []*Article
+	db.Unscoped().Preload("Tag").Preload("Author").Where("author_id = ?", userID).Order("id").Find(&articles)
+	for _, article := range articles {
+		export.Articles = append(export.Articles, &ExportedArticle{
+			ArticleResponse: db.PrepareArticle(article),
+			DeletedAt:       exportTime(article.DeletedAt),
+		})
+	}
+
+	var comments []*ArticleComment
+	db.Unscoped().Preload("Author").Preload("Article", func(sql *gorm.DB) *gorm.DB {
+		return sql.Unscoped()
+	}).Where("author_id = ?", userID).Order("id").Find(&comments)
+	for _, comment := range comments {
+		export.Comments = append(export.Comments, &ExportedComment{
+			CommentResponse: db.PrepareComment(comment),
+			ArticleSlug:     comment.Article.Slug,
+			DeletedAt:       exportTime(comment.DeletedAt),
+		})
+	}
+
+	export.Favorites = db.exportReferences(`SELECT articles.slug, article_favorites.created_at
+		FROM article_favorites JOIN articles ON articles.id = article_favorites.article_id
+		WHERE article_favorites.user_id = ? ORDER BY article_favorites.id`, userID)
+	export.Bookmarks = db.exportReferences(`SELECT articles.slug, bookmarks.created_at
+		FROM bookmarks JOIN articles ON articles.id = bookmarks.article_id
+		WHERE bookmarks.user_id = ? ORDER BY bookmarks.id`, userID)
+	export.Following = db.exportReferences(`SELECT users.username, followers.created_at
+		FROM followers JOIN users ON users.id = followers.following_id
+		WHERE followers.follower_id = ? ORDER BY followers.id`, userID)
+	export.Followers = db.exportReferences(`SELECT users.username, followers.created_at
+		FROM followers JOIN users ON users.id = followers.follower_id
+		WHERE followers.following_id = ? ORDER BY followers.id`, userID)
+	return export
+}
+
+// exportReferences runs a query selecting a name and a creation time.
+func (db *DB) exportReferences(query string, userID uint) []*ExportedReference {
+	references := []*ExportedReference{}
+
+	rows, err := db.Raw(query, userID).Rows()
+	if err != nil {
+		return references
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var createdAt time.Time
+		if rows.Scan(&name, &createdAt) == nil {
+			references = append(references, &ExportedReference{
+				Name:      name,
+				CreatedAt: createdAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+			})
+		}
+	}
+	return references
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+// TestListQueriesDoNotGrowWithPageSize checks that the list endpoints load
+// tags, authors, favorites, bookmarks and follows in batches rather than per
+// article or comment.
+func TestListQueriesDoNotGrowWithPageSize(t *testing.T) {
+	db := testDB(t)
+	reader := createTestUser(t, db, "reader")
+
+	var articles []*Article
+	for i := 0; i < 5; i++ {
+		author := createTestUser(t, db, fmt.Sprintf("author%d", i))
+		db.Follow(reader.ID, author.ID)
+		article := createTestArticle(t, db, fmt.Sprintf("Article %d", i), "body",
+			[]string{"go", fmt.Sprintf("tag%d", i)}, author.ID)
+		db.FavoriteArticle(article.ID, reader.ID)
+		db.BookmarkArticle(article.ID, reader.ID)
+		articles = append(articles, article)
+	}
+
+	// The first article has one comment, the last one has a comment from
+	// every author.
+	createTestComment(t, db, articles[0], reader.ID, "comment")
+	last := articles[len(articles)-1]
+	for _, article := range articles {
+		createTestComment(t, db, last, article.AuthorID, "comment")
+	}
+
+	lists := map[string]func(db *DB, limit int) int{
+		"articles": func(db *DB, limit int) int {
+			queries := url.Values{"limit": {fmt.Sprint(limit)}}
+			return len(db.ListArticleWithUser(queries, reader.ID).Articles)
+		},
+		"feed": func(db *DB, limit int) int {
+			queries := url.Values{"limit": {fmt.Sprint(limit)}}
+			return len(db.ListArticleFeed(queries, reader.ID).Articles)
+		},
+		"comments": func(db *DB, limit int) int {
+			// Comments aren't paginated, so pick the article by how many
+			// comments are wanted.
+			article := articles[0]
+			if limit > 1 {
+				article = last
+			}
+			return len(db.ListArticleCommentWithUser(article.ID, reader.ID).Comments)
+		},
+	}
+
+	for name, list := range lists {
+		counted, counter := countQueries(db)
+
+		if n := list(counted, 1); n == 0 {
+			t.Fatalf("%s: empty page", name)
+		}
+		one := counter.queries
+
+		counter.queries = 0
+		if n := list(counted, len(articles)); n < len(articles) {
+			t.Fatalf("%s: got %d items, want at least %d", name, n, len(articles))
+		}
+		if counter.queries != one {
+			t.Errorf("%s: %d queries for a page of %d, %d for a page of 1", name, counter.queries, len(articles), one)
+		}
+	}
+}
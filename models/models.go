@@ -1,9 +1,196 @@
 package models
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
 	"github.com/jinzhu/gorm"
+
+	"github.com/koyoyo/realworld-starter-kit/events"
 )
 
 type DB struct {
 	*gorm.DB
 }
+
+// Schema lists every model managed by AutoMigrate, in migration order.
+var Schema = []interface{}{
+	&User{},
+	&Follower{},
+	&Block{},
+	&Mute{},
+	&Article{},
+	&ArticleFavorite{},
+	&ArticleComment{},
+	&Tag{},
+	&TagFollow{},
+	&RelatedArticle{},
+	&Recommendation{},
+	&Asset{},
+	&Bookmark{},
+	&ReadingList{},
+	&ReadingListItem{},
+	&Notification{},
+	&NotificationActor{},
+	&NotificationPreference{},
+	&Webhook{},
+	&WebhookDelivery{},
+	&Report{},
+	&AccountDeletion{},
+	&events.OutboxEvent{},
+	&events.ProcessedEvent{},
+}
+
+// DataMigration records a one-off data migration that has been applied.
+type DataMigration struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	Name      string `gorm:"unique_index"`
+}
+
+// dataMigrations run once each, in order, after the schema is migrated.
+var dataMigrations = []struct {
+	Name string
+	Run  func(tx *gorm.DB) error
+}{
+	{"0001_swap_follower_columns", swapFollowerColumns},
+	{"0002_normalize_tags", normalizeTags},
+	{"0003_backfill_comments_count", backfillCommentsCount},
+}
+
+func (db *DB) Migrate() {
+	for _, model := range Schema {
+		db.AutoMigrate(model)
+	}
+	db.AutoMigrate(&DataMigration{})
+
+	for _, migration := range dataMigrations {
+		var count uint
+		db.Model(&DataMigration{}).Where(&DataMigration{Name: migration.Name}).Count(&count)
+		if count > 0 {
+			continue
+		}
+
+		tx := db.Begin()
+		if err := migration.Run(tx); err != nil {
+			tx.Rollback()
+			panic(fmt.Errorf("Data migration %s: %s", migration.Name, err))
+		}
+		tx.Create(&DataMigration{Name: migration.Name})
+		tx.Commit()
+	}
+}
+
+// swapFollowerColumns fixes rows written while Follower stored the followed
+// user in follower_id. Going through negative ids keeps the unique index
+// satisfied while mirrored pairs are swapped.
+func swapFollowerColumns(tx *gorm.DB) error {
+	if err := exec(tx, "UPDATE followers SET follower_id = -following_id, following_id = follower_id").Error; err != nil {
+		return err
+	}
+	return exec(tx, "UPDATE followers SET follower_id = -follower_id").Error
+}
+
+// backfillCommentsCount fills in CommentsCount for articles commented on
+// before the column existed.
+func backfillCommentsCount(tx *gorm.DB) error {
+	return exec(tx, `UPDATE articles SET comments_count = counts.total
+		FROM (
+			SELECT article_id, COUNT(*) AS total FROM article_comments GROUP BY article_id
+		) AS counts
+		WHERE articles.id = counts.article_id`).Error
+}
+
+// schemaTables lists the tables Migrate creates: those of Schema, their
+// many-to-many join tables and data_migrations.
+func (db *DB) schemaTables() []string {
+	var tables []string
+	for _, model := range Schema {
+		scope := db.NewScope(model)
+		tables = append(tables, scope.TableName())
+		for _, field := range scope.GetModelStruct().StructFields {
+			if r := field.Relationship; r != nil && r.Kind == "many_to_many" && r.JoinTableHandler != nil {
+				tables = append(tables, r.JoinTableHandler.Table(db.DB))
+			}
+		}
+	}
+	return append(tables, db.NewScope(&DataMigration{}).TableName())
+}
+
+// SchemaStatus returns the tables Migrate creates that don't exist yet and
+// the registered data migrations that haven't been applied. It gives up with
+// ctx's error once ctx is done.
+func (db *DB) SchemaStatus(ctx context.Context) (missingTables, pendingMigrations []string, err error) {
+	conn := db.DB.DB()
+	existing, err := queryNames(ctx, conn,
+		"SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema()")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, table := range db.schemaTables() {
+		if !existing[table] && !seen[table] {
+			missingTables = append(missingTables, table)
+		}
+		seen[table] = true
+	}
+
+	applied := map[string]bool{}
+	if existing[db.NewScope(&DataMigration{}).TableName()] {
+		if applied, err = queryNames(ctx, conn, "SELECT name FROM data_migrations"); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, migration := range dataMigrations {
+		if !applied[migration.Name] {
+			pendingMigrations = append(pendingMigrations, migration.Name)
+		}
+	}
+	return missingTables, pendingMigrations, nil
+}
+
+// queryNames returns the set of strings in the single column query selects.
+func queryNames(ctx context.Context, conn *sql.DB, query string) (map[string]bool, error) {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names[name] = true
+	}
+	return names, rows.Err()
+}
+
+func (db *DB) Ping(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return db.DB.DB().PingContext(ctx)
+}
+
+// pagination reads the limit and offset query parameters.
+func pagination(queries url.Values) (limit, offset int) {
+	limit = 20
+	if limitStr, ok := queries["limit"]; ok {
+		if limitTmp, err := strconv.Atoi(limitStr[0]); err == nil {
+			limit = limitTmp
+		}
+	}
+	if offsetStr, ok := queries["offset"]; ok {
+		if offsetTmp, err := strconv.Atoi(offsetStr[0]); err == nil {
+			offset = offsetTmp
+		}
+	}
+	return
+}
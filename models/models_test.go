@@ -0,0 +1,38 @@
+package models
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSchemaStatus(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	missing, pending, err := db.SchemaStatus(ctx)
+	if err != nil || len(missing) > 0 || len(pending) > 0 {
+		t.Fatalf("migrated database: missing %v, pending %v, %v", missing, pending, err)
+	}
+
+	// testDB migrates again, bringing both back for the next test.
+	db.Exec("DROP TABLE article_tags")
+	db.Exec("DELETE FROM data_migrations WHERE name = ?", "0003_backfill_comments_count")
+
+	missing, pending, err = db.SchemaStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(missing, []string{"article_tags"}) {
+		t.Errorf("missing tables = %v, want [article_tags]", missing)
+	}
+	if !reflect.DeepEqual(pending, []string{"0003_backfill_comments_count"}) {
+		t.Errorf("pending migrations = %v, want [0003_backfill_comments_count]", pending)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, err := db.SchemaStatus(canceled); err == nil {
+		t.Error("SchemaStatus ignored a canceled context")
+	}
+}
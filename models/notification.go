@@ -0,0 +1,311 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/koyoyo/realworld-starter-kit/events"
+)
+
+const (
+	CommentNotification  = "comment"
+	FavoriteNotification = "favorite"
+	FollowNotification   = "follow"
+)
+
+// notificationGroupWindow is how long an unread grouped notification keeps
+// absorbing new actors before a fresh one is started.
+const notificationGroupWindow = 24 * time.Hour
+
+// Notification tells UserID that ActorID did something. Notifications sharing
+// a GroupKey (e.g. every favorite of one article) are folded together while
+// unread: ActorID is the latest actor and ActorsCount counts distinct actors.
+type Notification struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID      uint `gorm:"index"`
+	Kind        string
+	GroupKey    string `gorm:"index"`
+	ActorID     uint
+	Actor       User
+	ActorsCount uint
+	ArticleID   uint
+	Article     Article
+	CommentID   uint
+	Comment     ArticleComment
+	ReadAt      *time.Time
+}
+
+// NotificationActor lets grouped notifications count each actor once.
+type NotificationActor struct {
+	ID             uint `gorm:"primary_key"`
+	NotificationID uint `gorm:"unique_index:notification_actor"`
+	ActorID        uint `gorm:"unique_index:notification_actor"`
+}
+
+// NotificationPreference holds which kinds of notifications a user wants.
+// Users without a row get everything.
+type NotificationPreference struct {
+	ID        uint `gorm:"primary_key"`
+	UpdatedAt time.Time
+
+	UserID   uint `gorm:"unique_index"`
+	Comment  bool
+	Favorite bool
+	Follow   bool
+}
+
+type NotificationArticle struct {
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+}
+
+type NotificationComment struct {
+	ID   uint   `json:"id"`
+	Body string `json:"body"`
+}
+
+type NotificationResponse struct {
+	ID          uint                 `json:"id"`
+	CreatedAt   string               `json:"createdAt"`
+	UpdatedAt   string               `json:"updatedAt"`
+	Kind        string               `json:"kind"`
+	Read        bool                 `json:"read"`
+	Actor       *Author              `json:"actor"`
+	ActorsCount uint                 `json:"actorsCount"`
+	Article     *NotificationArticle `json:"article,omitempty"`
+	Comment     *NotificationComment `json:"comment,omitempty"`
+}
+
+type NotificationsResponseJson struct {
+	Notifications      []*NotificationResponse `json:"notifications"`
+	NotificationsCount uint                    `json:"notificationsCount"`
+	UnreadCount        uint                    `json:"unreadCount"`
+}
+
+type NotificationPreferenceResponse struct {
+	Comment  bool `json:"comment"`
+	Favorite bool `json:"favorite"`
+	Follow   bool `json:"follow"`
+}
+
+type NotificationPreferenceResponseJson struct {
+	Preferences NotificationPreferenceResponse `json:"preferences"`
+}
+
+// NotificationHook, when set, is called with every new or updated
+// notification so it can be pushed to connected clients.
+var NotificationHook func(notification *Notification)
+
+// notify records a notification for recipientID, folding it into a recent
+// unread one with the same groupKey when groupKey isn't empty. The hook runs
+// once the envelope's transaction has committed.
+func notify(tx *gorm.DB, envelope *events.Envelope, recipientID, actorID uint, kind, groupKey string,
+	articleID, commentID uint) error {
+	if recipientID == 0 || recipientID == actorID {
+		return nil
+	}
+
+	if !notificationWanted(tx, recipientID, kind) {
+		return nil
+	}
+
+	var notification Notification
+	if groupKey != "" {
+		err := tx.Where("user_id = ? AND group_key = ? AND read_at IS NULL AND updated_at > ?",
+			recipientID, groupKey, time.Now().Add(-notificationGroupWindow)).
+			Order("id desc").First(&notification).Error
+		if err != nil && !gorm.IsRecordNotFoundError(err) {
+			return err
+		}
+	}
+
+	if notification.ID == 0 {
+		notification = Notification{
+			UserID:      recipientID,
+			Kind:        kind,
+			GroupKey:    groupKey,
+			ActorID:     actorID,
+			ActorsCount: 1,
+			ArticleID:   articleID,
+			CommentID:   commentID,
+		}
+		if err := tx.Create(&notification).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&NotificationActor{NotificationID: notification.ID, ActorID: actorID}).Error; err != nil {
+			return err
+		}
+	} else {
+		// Create would fail with sql.ErrNoRows when the actor is already
+		// listed, so insert directly and look at RowsAffected.
+		results := exec(tx, `INSERT INTO notification_actors (notification_id, actor_id) VALUES (?, ?)
+			ON CONFLICT DO NOTHING`, notification.ID, actorID)
+		if results.Error != nil || results.RowsAffected == 0 {
+			return results.Error
+		}
+
+		err := tx.Model(&notification).Updates(map[string]interface{}{
+			"actor_id":     actorID,
+			"actors_count": gorm.Expr("actors_count + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	if NotificationHook != nil {
+		var updated Notification
+		if err := tx.Preload("Actor").Preload("Article").Preload("Comment").First(&updated, notification.ID).Error; err != nil {
+			return err
+		}
+		envelope.AfterCommit(func() { NotificationHook(&updated) })
+	}
+	return nil
+}
+
+func notificationWanted(tx *gorm.DB, userID uint, kind string) bool {
+	var preference NotificationPreference
+	tx.Where(&NotificationPreference{UserID: userID}).First(&preference)
+	if preference.ID == 0 {
+		return true
+	}
+
+	switch kind {
+	case CommentNotification:
+		return preference.Comment
+	case FavoriteNotification:
+		return preference.Favorite
+	case FollowNotification:
+		return preference.Follow
+	}
+	return true
+}
+
+func favoriteGroupKey(articleID uint) string {
+	return fmt.Sprintf("favorite:%d", articleID)
+}
+
+func (db *DB) ListNotifications(queries url.Values, userID uint) *NotificationsResponseJson {
+	sql := db.Where(&Notification{UserID: userID})
+	if unread, ok := queries["unread"]; ok && unread[0] == "true" {
+		sql = sql.Where("read_at IS NULL")
+	}
+
+	limit, offset := pagination(queries)
+
+	var count, unreadCount uint
+	var notifications []*Notification
+	sql.Model(&Notification{}).Count(&count)
+	db.Model(&Notification{}).Where(&Notification{UserID: userID}).Where("read_at IS NULL").Count(&unreadCount)
+	sql.Preload("Actor").Preload("Article").Preload("Comment").
+		Order("updated_at desc").Offset(offset).Limit(limit).Find(&notifications)
+
+	notificationsResponse := []*NotificationResponse{}
+	for _, notification := range notifications {
+		notificationsResponse = append(notificationsResponse, db.PrepareNotification(notification))
+	}
+
+	return &NotificationsResponseJson{
+		Notifications:      notificationsResponse,
+		NotificationsCount: count,
+		UnreadCount:        unreadCount,
+	}
+}
+
+// MarkNotificationRead returns false when the notification doesn't belong to
+// userID.
+func (db *DB) MarkNotificationRead(notificationID, userID uint) bool {
+	var notification Notification
+	db.Where(&Notification{ID: notificationID, UserID: userID}).First(&notification)
+	if notification.ID == 0 {
+		return false
+	}
+
+	if notification.ReadAt == nil {
+		db.Model(&notification).UpdateColumn("read_at", time.Now())
+	}
+	return true
+}
+
+func (db *DB) MarkAllNotificationsRead(userID uint) {
+	db.Model(&Notification{}).Where(&Notification{UserID: userID}).Where("read_at IS NULL").
+		UpdateColumn("read_at", time.Now())
+}
+
+func (db *DB) GetNotificationPreference(userID uint) *NotificationPreferenceResponseJson {
+	var preference NotificationPreference
+	db.Where(&NotificationPreference{UserID: userID}).First(&preference)
+	if preference.ID == 0 {
+		preference = NotificationPreference{Comment: true, Favorite: true, Follow: true}
+	}
+
+	return &NotificationPreferenceResponseJson{
+		Preferences: NotificationPreferenceResponse{
+			Comment:  preference.Comment,
+			Favorite: preference.Favorite,
+			Follow:   preference.Follow,
+		},
+	}
+}
+
+// UpdateNotificationPreference changes the given kinds, leaving nil ones as
+// they were.
+func (db *DB) UpdateNotificationPreference(userID uint, comment, favorite, follow *bool) *NotificationPreferenceResponseJson {
+	var preference NotificationPreference
+	db.Where(&NotificationPreference{UserID: userID}).First(&preference)
+	if preference.ID == 0 {
+		preference = NotificationPreference{UserID: userID, Comment: true, Favorite: true, Follow: true}
+	}
+
+	if comment != nil {
+		preference.Comment = *comment
+	}
+	if favorite != nil {
+		preference.Favorite = *favorite
+	}
+	if follow != nil {
+		preference.Follow = *follow
+	}
+	db.Save(&preference)
+
+	return db.GetNotificationPreference(userID)
+}
+
+func (db *DB) PrepareNotification(notification *Notification) *NotificationResponse {
+	notificationResponse := &NotificationResponse{
+		ID:          notification.ID,
+		CreatedAt:   notification.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		UpdatedAt:   notification.UpdatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		Kind:        notification.Kind,
+		Read:        notification.ReadAt != nil,
+		ActorsCount: notification.ActorsCount,
+		Actor: &Author{
+			ID:       notification.Actor.ID,
+			Username: notification.Actor.Username,
+			Bio:      notification.Actor.Bio,
+			Image:    notification.Actor.Image,
+		},
+	}
+
+	if notification.Article.ID != 0 {
+		notificationResponse.Article = &NotificationArticle{
+			Slug:  notification.Article.Slug,
+			Title: notification.Article.Title,
+		}
+	}
+
+	if notification.Comment.ID != 0 {
+		notificationResponse.Comment = &NotificationComment{
+			ID:   notification.Comment.ID,
+			Body: notification.Comment.Body,
+		}
+	}
+
+	return notificationResponse
+}
@@ -1,9 +1,15 @@
 package models
 
 import (
+	"net/url"
 	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/koyoyo/realworld-starter-kit/events"
 )
 
+// Follower records that the user FollowerID follows the user FollowingID.
 type Follower struct {
 	ID          uint `gorm:"primary_key"`
 	CreatedAt   time.Time
@@ -14,21 +20,31 @@ type Follower struct {
 }
 
 type Profile struct {
-	ID        uint    `json:"-"`
-	Username  string  `json:"username"`
-	Bio       string  `json:"bio"`
-	Image     *string `json:"image"`
-	Following bool    `json:"following"`
+	ID             uint    `json:"-"`
+	Username       string  `json:"username"`
+	Bio            string  `json:"bio"`
+	Image          *string `json:"image"`
+	Following      bool    `json:"following"`
+	FollowersCount uint    `json:"followersCount"`
+	FollowingCount uint    `json:"followingCount"`
+	Blocking       bool    `json:"blocking"`
+	Muting         bool    `json:"muting"`
 }
 
 type ProfileResponse struct {
 	Profile Profile `json:"profile"`
 }
 
+type ProfilesResponseJson struct {
+	Profiles      []*Profile `json:"profiles"`
+	ProfilesCount uint       `json:"profilesCount"`
+}
+
 func (db *DB) GetUserProfile(username string) *ProfileResponse {
 	user := User{}
 	db.Where(&User{Username: username}).First(&user)
-	return &ProfileResponse{
+
+	profile := &ProfileResponse{
 		Profile: Profile{
 			ID:       user.ID,
 			Username: user.Username,
@@ -36,19 +52,113 @@ func (db *DB) GetUserProfile(username string) *ProfileResponse {
 			Image:    user.Image,
 		},
 	}
+	if user.ID != 0 {
+		db.Model(&Follower{}).Where(&Follower{FollowingID: user.ID}).Count(&profile.Profile.FollowersCount)
+		db.Model(&Follower{}).Where(&Follower{FollowerID: user.ID}).Count(&profile.Profile.FollowingCount)
+	}
+	return profile
 }
 
+// IsFollowing reports whether followerID follows followingID.
 func (db *DB) IsFollowing(followerID, followingID uint) bool {
 	var count uint
 	db.Model(&Follower{}).Where(&Follower{FollowerID: followerID, FollowingID: followingID}).Count(&count)
 	return count > 0
 }
 
+// followingUserIDs resolves IsFollowing for a set of users in a single query.
+func (db *DB) followingUserIDs(followerID uint, followingIDs []uint) map[uint]bool {
+	following := map[uint]bool{}
+	if len(followingIDs) == 0 {
+		return following
+	}
+
+	var ids []uint
+	db.Model(&Follower{}).Where("follower_id = ? AND following_id IN (?)", followerID, followingIDs).
+		Pluck("following_id", &ids)
+	for _, id := range ids {
+		following[id] = true
+	}
+	return following
+}
+
+// FollowingIDs returns the ids of the users userID follows.
+func (db *DB) FollowingIDs(userID uint) []uint {
+	var ids []uint
+	db.Model(&Follower{}).Where(&Follower{FollowerID: userID}).Pluck("following_id", &ids)
+	return ids
+}
+
+// Follow makes followerID follow followingID.
 func (db *DB) Follow(followerID, followingID uint) {
+	tx := db.Begin()
 	follower := Follower{}
-	db.FirstOrCreate(&follower, Follower{FollowerID: followerID, FollowingID: followingID})
+	results := tx.FirstOrCreate(&follower, Follower{FollowerID: followerID, FollowingID: followingID})
+	if results.RowsAffected > 0 {
+		events.Record(tx, &events.UserFollowed{FollowerID: followerID, FollowingID: followingID})
+	}
+	tx.Commit()
 }
 
 func (db *DB) Unfollow(followerID, followingID uint) {
-	db.Where(&Follower{FollowerID: followerID, FollowingID: followingID}).Delete(Follower{})
+	tx := db.Begin()
+	results := tx.Where(&Follower{FollowerID: followerID, FollowingID: followingID}).Delete(Follower{})
+	if results.RowsAffected > 0 {
+		events.Record(tx, &events.UserUnfollowed{FollowerID: followerID, FollowingID: followingID})
+	}
+	tx.Commit()
+}
+
+// ListFollowers lists the users following userID.
+func (db *DB) ListFollowers(queries url.Values, userID uint) *ProfilesResponseJson {
+	sql := db.Table("users").
+		Joins("JOIN followers ON followers.follower_id=users.id").
+		Where("followers.following_id = ? AND users.deleted_at IS NULL", userID)
+	return db.listProfiles(sql, queries)
+}
+
+// ListFollowing lists the users userID follows.
+func (db *DB) ListFollowing(queries url.Values, userID uint) *ProfilesResponseJson {
+	sql := db.Table("users").
+		Joins("JOIN followers ON followers.following_id=users.id").
+		Where("followers.follower_id = ? AND users.deleted_at IS NULL", userID)
+	return db.listProfiles(sql, queries)
+}
+
+func (db *DB) listProfiles(sql *gorm.DB, queries url.Values) *ProfilesResponseJson {
+	limit, offset := pagination(queries)
+
+	var count uint
+	var users []*User
+	sql.Count(&count)
+	sql.Select("users.*").Order("followers.id desc").Offset(offset).Limit(limit).Find(&users)
+
+	profiles := []*Profile{}
+	for _, user := range users {
+		profiles = append(profiles, &Profile{
+			ID:       user.ID,
+			Username: user.Username,
+			Bio:      user.Bio,
+			Image:    user.Image,
+		})
+	}
+
+	return &ProfilesResponseJson{
+		Profiles:      profiles,
+		ProfilesCount: count,
+	}
+}
+
+// SetFollowing fills the Following flag of profiles from the point of view
+// of userID.
+func (db *DB) SetFollowing(profiles []*Profile, userID uint) {
+	var ids []uint
+	for _, profile := range profiles {
+		ids = append(ids, profile.ID)
+	}
+
+	following := db.followingUserIDs(userID, ids)
+	for _, profile := range profiles {
+		profile.Following = following[profile.ID]
+	}
 }
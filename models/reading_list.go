@@ -0,0 +1,287 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+var ErrReorderMismatch = errors.New("slugs must list every article of the reading list exactly once")
+
+// Bookmark privately saves an article for a user. Unlike ArticleFavorite it
+// is never shown to other users and doesn't affect FavoritesCount.
+type Bookmark struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+
+	UserID    uint `gorm:"unique_index:bookmark"`
+	User      User
+	ArticleID uint `gorm:"unique_index:bookmark"`
+	Article   Article
+}
+
+// ReadingList is a named, ordered collection of articles. Lists are private
+// unless Public is set, in which case anyone holding ShareToken can read them.
+type ReadingList struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	OwnerID    uint `gorm:"index"`
+	Owner      User
+	Name       string
+	Public     bool
+	ShareToken *string `gorm:"unique_index"`
+}
+
+type ReadingListItem struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+
+	ReadingListID uint `gorm:"unique_index:reading_list_item"`
+	ArticleID     uint `gorm:"unique_index:reading_list_item"`
+	Article       Article
+	Position      int
+}
+
+type ReadingListResponse struct {
+	ID            uint               `json:"id"`
+	CreatedAt     string             `json:"createdAt"`
+	UpdatedAt     string             `json:"updatedAt"`
+	Name          string             `json:"name"`
+	Public        bool               `json:"public"`
+	ShareToken    *string            `json:"shareToken"`
+	ArticlesCount uint               `json:"articlesCount"`
+	Articles      []*ArticleResponse `json:"articles,omitempty"`
+}
+
+type ReadingListResponseJson struct {
+	List *ReadingListResponse `json:"list"`
+}
+
+type ReadingListsResponseJson struct {
+	Lists []*ReadingListResponse `json:"lists"`
+}
+
+func (db *DB) IsBookmarked(articleID, userID uint) bool {
+	var count uint
+	db.Model(&Bookmark{}).Where(&Bookmark{UserID: userID, ArticleID: articleID}).Count(&count)
+	return count > 0
+}
+
+// bookmarkedArticleIDs resolves IsBookmarked for a whole page of articles in a
+// single query.
+func (db *DB) bookmarkedArticleIDs(articleIDs []uint, userID uint) map[uint]bool {
+	bookmarked := map[uint]bool{}
+	if len(articleIDs) == 0 {
+		return bookmarked
+	}
+
+	var ids []uint
+	db.Model(&Bookmark{}).Where("user_id = ? AND article_id IN (?)", userID, articleIDs).
+		Pluck("article_id", &ids)
+	for _, id := range ids {
+		bookmarked[id] = true
+	}
+	return bookmarked
+}
+
+func (db *DB) BookmarkArticle(articleID, userID uint) {
+	bookmark := Bookmark{}
+	db.FirstOrCreate(&bookmark, Bookmark{UserID: userID, ArticleID: articleID})
+}
+
+func (db *DB) UnbookmarkArticle(articleID, userID uint) {
+	db.Where(&Bookmark{UserID: userID, ArticleID: articleID}).Delete(Bookmark{})
+}
+
+func (db *DB) ListBookmarks(queries url.Values, userID uint) *ArticlesResponseJson {
+	sql := db.Preload("Tag").Preload("Author").
+		Joins("JOIN bookmarks ON bookmarks.article_id=articles.id").
+		Where("bookmarks.user_id = ?", userID).
+		Where("articles.hidden = ?", false).
+		Order("bookmarks.id desc")
+
+	limit, offset := pagination(queries)
+
+	var count uint
+	var articles []*Article
+	sql.Model(&Article{}).Count(&count)
+	sql.Offset(offset).Limit(limit).Find(&articles)
+	return db.PrepareArticlesResponseWithUser(articles, count, userID)
+}
+
+func (db *DB) CreateReadingList(ownerID uint, name string, public bool) *ReadingListResponseJson {
+	list := ReadingList{
+		OwnerID: ownerID,
+		Name:    name,
+	}
+	list.setPublic(public)
+	db.Create(&list)
+	return db.PrepareReadingListResponse(&list, ownerID)
+}
+
+func (db *DB) GetReadingList(listID, ownerID uint) *ReadingList {
+	var list ReadingList
+	db.Where(&ReadingList{ID: listID, OwnerID: ownerID}).First(&list)
+	return &list
+}
+
+// GetSharedReadingList finds a public list by its share token.
+func (db *DB) GetSharedReadingList(shareToken string) *ReadingList {
+	var list ReadingList
+	db.Where("share_token = ? AND public = ?", shareToken, true).First(&list)
+	return &list
+}
+
+func (db *DB) ListReadingLists(ownerID uint) *ReadingListsResponseJson {
+	var lists []*ReadingList
+	db.Where(&ReadingList{OwnerID: ownerID}).Order("ID desc").Find(&lists)
+
+	listsResponse := []*ReadingListResponse{}
+	for _, list := range lists {
+		listsResponse = append(listsResponse, db.prepareReadingList(list))
+	}
+
+	return &ReadingListsResponseJson{
+		Lists: listsResponse,
+	}
+}
+
+func (db *DB) UpdateReadingList(list *ReadingList, name string, public *bool) *ReadingListResponseJson {
+	if name != "" {
+		list.Name = name
+	}
+
+	if public != nil {
+		list.setPublic(*public)
+	}
+	db.Save(list)
+
+	return db.PrepareReadingListResponse(list, list.OwnerID)
+}
+
+func (db *DB) DeleteReadingList(list *ReadingList) {
+	tx := db.Begin()
+	tx.Where(&ReadingListItem{ReadingListID: list.ID}).Delete(ReadingListItem{})
+	tx.Delete(list)
+	tx.Commit()
+}
+
+// AddToReadingList appends the article to the end of the list.
+func (db *DB) AddToReadingList(list *ReadingList, articleID uint) {
+	var position int
+	db.Model(&ReadingListItem{}).Where(&ReadingListItem{ReadingListID: list.ID}).
+		Select("COALESCE(MAX(position), 0)").Row().Scan(&position)
+
+	item := ReadingListItem{}
+	db.Where(ReadingListItem{ReadingListID: list.ID, ArticleID: articleID}).
+		Attrs(ReadingListItem{Position: position + 1}).
+		FirstOrCreate(&item)
+	db.Model(list).UpdateColumn("updated_at", time.Now())
+}
+
+func (db *DB) RemoveFromReadingList(list *ReadingList, articleID uint) {
+	db.Where(&ReadingListItem{ReadingListID: list.ID, ArticleID: articleID}).Delete(ReadingListItem{})
+	db.Model(list).UpdateColumn("updated_at", time.Now())
+}
+
+// ReorderReadingList sets the order of the list to the given article slugs,
+// which must name every article of the list exactly once.
+func (db *DB) ReorderReadingList(list *ReadingList, slugs []string) error {
+	var items []*ReadingListItem
+	db.Preload("Article").Where(&ReadingListItem{ReadingListID: list.ID}).Find(&items)
+
+	bySlug := map[string]*ReadingListItem{}
+	for _, item := range items {
+		bySlug[item.Article.Slug] = item
+	}
+	if len(slugs) != len(items) {
+		return ErrReorderMismatch
+	}
+
+	tx := db.Begin()
+	for position, slug := range slugs {
+		item, ok := bySlug[slug]
+		if !ok {
+			tx.Rollback()
+			return ErrReorderMismatch
+		}
+		delete(bySlug, slug)
+
+		if err := tx.Model(item).UpdateColumn("position", position+1).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	tx.Model(list).UpdateColumn("updated_at", time.Now())
+	return tx.Commit().Error
+}
+
+// PrepareReadingListResponse includes the list's articles in order. userID is
+// used for the per-user flags of the articles and may be 0 for anonymous
+// readers of a shared list.
+func (db *DB) PrepareReadingListResponse(list *ReadingList, userID uint) *ReadingListResponseJson {
+	var items []*ReadingListItem
+	db.Preload("Article", "hidden = ?", false).Preload("Article.Tag").Preload("Article.Author").
+		Where(&ReadingListItem{ReadingListID: list.ID}).Order("position asc").Find(&items)
+
+	var articles []*Article
+	for _, item := range items {
+		// Soft-deleted and hidden articles are left out by the preload.
+		if item.Article.ID != 0 {
+			article := item.Article
+			articles = append(articles, &article)
+		}
+	}
+
+	var articlesResponse *ArticlesResponseJson
+	if userID != 0 {
+		articlesResponse = db.PrepareArticlesResponseWithUser(articles, uint(len(articles)), userID)
+	} else {
+		articlesResponse = db.PrepareArticlesResponse(articles, uint(len(articles)))
+	}
+
+	listResponse := db.prepareReadingList(list)
+	listResponse.ArticlesCount = articlesResponse.ArticlesCount
+	listResponse.Articles = articlesResponse.Articles
+
+	return &ReadingListResponseJson{
+		List: listResponse,
+	}
+}
+
+func (db *DB) prepareReadingList(list *ReadingList) *ReadingListResponse {
+	var count uint
+	db.Model(&ReadingListItem{}).Where(&ReadingListItem{ReadingListID: list.ID}).
+		Joins("JOIN articles ON articles.id = reading_list_items.article_id AND articles.deleted_at IS NULL").
+		Where("articles.hidden = ?", false).
+		Count(&count)
+
+	return &ReadingListResponse{
+		ID:            list.ID,
+		CreatedAt:     list.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		UpdatedAt:     list.UpdatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		Name:          list.Name,
+		Public:        list.Public,
+		ShareToken:    list.ShareToken,
+		ArticlesCount: count,
+	}
+}
+
+// setPublic toggles sharing. A share token is minted the first time the list
+// is made public and kept afterwards so old links work again if re-shared.
+func (list *ReadingList) setPublic(public bool) {
+	list.Public = public
+	if public && list.ShareToken == nil {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			panic(fmt.Errorf("Share token err: %s", err))
+		}
+		token := hex.EncodeToString(buf)
+		list.ShareToken = &token
+	}
+}
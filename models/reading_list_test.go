@@ -0,0 +1,35 @@
+package models
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestHiddenArticlesLeaveReadingLists(t *testing.T) {
+	db := testDB(t)
+	author := createTestUser(t, db, "author")
+	reader := createTestUser(t, db, "reader")
+
+	article := createTestArticle(t, db, "Soon hidden", "body", nil, author.ID)
+	db.BookmarkArticle(article.ID, reader.ID)
+	list := db.GetReadingList(db.CreateReadingList(reader.ID, "Later", false).List.ID, reader.ID)
+	db.AddToReadingList(list, article.ID)
+
+	if n := len(db.ListBookmarks(url.Values{}, reader.ID).Articles); n != 1 {
+		t.Fatalf("%d bookmarks before hiding, want 1", n)
+	}
+
+	db.Model(&Article{}).Where("id = ?", article.ID).UpdateColumn("hidden", true)
+
+	bookmarks := db.ListBookmarks(url.Values{}, reader.ID)
+	if len(bookmarks.Articles) != 0 || bookmarks.ArticlesCount != 0 {
+		t.Errorf("bookmarks list %d articles (count %d), want none", len(bookmarks.Articles), bookmarks.ArticlesCount)
+	}
+	response := db.PrepareReadingListResponse(list, reader.ID).List
+	if len(response.Articles) != 0 || response.ArticlesCount != 0 {
+		t.Errorf("reading list has %d articles (count %d), want none", len(response.Articles), response.ArticlesCount)
+	}
+	if summary := db.ListReadingLists(reader.ID); summary.Lists[0].ArticlesCount != 0 {
+		t.Errorf("reading list summary counts %d articles, want 0", summary.Lists[0].ArticlesCount)
+	}
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"net/url"
+)
+
+// RelatedArticle scores how close RelatedID is to ArticleID. Rows are
+// rebuilt offline by RecomputeRecommendations.
+type RelatedArticle struct {
+	ID uint `gorm:"primary_key"`
+
+	ArticleID uint `gorm:"unique_index:related_article"`
+	RelatedID uint `gorm:"unique_index:related_article"`
+	Score     float64
+}
+
+// Recommendation scores an article for a user. Rows are rebuilt offline by
+// RecomputeRecommendations.
+type Recommendation struct {
+	ID uint `gorm:"primary_key"`
+
+	UserID    uint `gorm:"unique_index:recommendation"`
+	ArticleID uint `gorm:"unique_index:recommendation"`
+	Score     float64
+}
+
+// recommendationsKept is how many related articles are kept per article and
+// how many recommendations are kept per user.
+const recommendationsKept = 50
+
+// RecomputeRecommendations rebuilds related_articles and recommendations
+// from tags, favorites and follows, returning how many rows of each it wrote.
+//
+// An article is related to another through every tag they share (1 point)
+// and every user who favorited both (2 points). A user is recommended
+// articles by authors they follow (2 points), carrying tags they follow
+// (1 point per tag) and related to the ones they favorited (half the related
+// score), leaving out their own and already favorited articles.
+func (db *DB) RecomputeRecommendations() (related, recommended int64, err error) {
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = tx.Delete(&RelatedArticle{}).Error; err != nil {
+		return
+	}
+	results := exec(tx, `INSERT INTO related_articles (article_id, related_id, score)
+		SELECT article_id, related_id, score FROM (
+			SELECT article_id, related_id, SUM(score) AS score,
+				ROW_NUMBER() OVER (PARTITION BY article_id ORDER BY SUM(score) DESC, related_id DESC) AS row_rank
+			FROM (
+				SELECT a.article_id, b.article_id AS related_id, 1.0 AS score
+				FROM article_tags a JOIN article_tags b ON a.tag_id = b.tag_id AND a.article_id <> b.article_id
+				UNION ALL
+				SELECT a.article_id, b.article_id AS related_id, 2.0 AS score
+				FROM article_favorites a JOIN article_favorites b ON a.user_id = b.user_id AND a.article_id <> b.article_id
+			) AS signals
+			JOIN articles ON articles.id = signals.related_id AND articles.deleted_at IS NULL AND articles.hidden = false
+			GROUP BY article_id, related_id
+		) AS ranked
+		WHERE row_rank <= ?`, recommendationsKept)
+	if err = results.Error; err != nil {
+		return
+	}
+	related = results.RowsAffected
+
+	if err = tx.Delete(&Recommendation{}).Error; err != nil {
+		return
+	}
+	results = exec(tx, `INSERT INTO recommendations (user_id, article_id, score)
+		SELECT user_id, article_id, score FROM (
+			SELECT user_id, article_id, SUM(score) AS score,
+				ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY SUM(score) DESC, article_id DESC) AS row_rank
+			FROM (
+				SELECT followers.follower_id AS user_id, articles.id AS article_id, 2.0 AS score
+				FROM followers JOIN articles ON articles.author_id = followers.following_id
+				UNION ALL
+				SELECT tag_follows.user_id, article_tags.article_id, 1.0 AS score
+				FROM tag_follows JOIN article_tags ON article_tags.tag_id = tag_follows.tag_id
+				UNION ALL
+				SELECT article_favorites.user_id, related_articles.related_id AS article_id, related_articles.score / 2 AS score
+				FROM article_favorites JOIN related_articles ON related_articles.article_id = article_favorites.article_id
+			) AS signals
+			JOIN articles ON articles.id = signals.article_id AND articles.deleted_at IS NULL AND articles.hidden = false
+			WHERE articles.author_id <> signals.user_id
+				AND NOT EXISTS (
+					SELECT 1 FROM article_favorites
+					WHERE article_favorites.user_id = signals.user_id AND article_favorites.article_id = signals.article_id
+				)
+			GROUP BY user_id, article_id
+		) AS ranked
+		WHERE row_rank <= ?`, recommendationsKept)
+	if err = results.Error; err != nil {
+		return
+	}
+	recommended = results.RowsAffected
+
+	err = tx.Commit().Error
+	return
+}
+
+// listRelatedArticles lists the articles related to articleID, leaving out
+// the authors viewerID muted or blocked.
+func (db *DB) listRelatedArticles(queries url.Values, articleID, viewerID uint) (articles []*Article, count uint) {
+	sql := db.Preload("Tag").Preload("Author").
+		Joins("JOIN related_articles ON related_articles.related_id=articles.id").
+		Where("related_articles.article_id = ? AND articles.hidden = ?", articleID, false).
+		Order("related_articles.score desc, articles.id desc")
+	sql = withoutSilenced(sql, "articles.author_id", viewerID)
+
+	limit, offset := pagination(queries)
+
+	sql.Model(&Article{}).Count(&count)
+	sql.Offset(offset).Limit(limit).Find(&articles)
+	return
+}
+
+func (db *DB) ListRelatedArticles(queries url.Values, articleID uint) *ArticlesResponseJson {
+	articles, count := db.listRelatedArticles(queries, articleID, 0)
+	return db.PrepareArticlesResponse(articles, count)
+}
+
+func (db *DB) ListRelatedArticlesWithUser(queries url.Values, articleID, userID uint) *ArticlesResponseJson {
+	articles, count := db.listRelatedArticles(queries, articleID, userID)
+	return db.PrepareArticlesResponseWithUser(articles, count, userID)
+}
+
+func (db *DB) ListRecommendations(queries url.Values, userID uint) *ArticlesResponseJson {
+	sql := db.Preload("Tag").Preload("Author").
+		Joins("JOIN recommendations ON recommendations.article_id=articles.id").
+		Where("recommendations.user_id = ? AND articles.hidden = ?", userID, false).
+		Order("recommendations.score desc, articles.id desc")
+	sql = withoutSilenced(sql, "articles.author_id", userID)
+
+	limit, offset := pagination(queries)
+
+	var count uint
+	var articles []*Article
+	sql.Model(&Article{}).Count(&count)
+	sql.Offset(offset).Limit(limit).Find(&articles)
+	return db.PrepareArticlesResponseWithUser(articles, count, userID)
+}
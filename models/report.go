@@ -0,0 +1,180 @@
+package models
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Report targets.
+const (
+	ReportArticle = "article"
+	ReportComment = "comment"
+	ReportUser    = "user"
+)
+
+// Report states.
+const (
+	ReportOpen      = "open"
+	ReportActioned  = "actioned"
+	ReportDismissed = "dismissed"
+)
+
+// Report flags an article, comment or user for the moderators. A user can
+// report the same target only once.
+type Report struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	ReporterID   uint `gorm:"unique_index:report"`
+	Reporter     User
+	TargetType   string `gorm:"unique_index:report;index:report_target"`
+	TargetID     uint   `gorm:"unique_index:report;index:report_target"`
+	Reason       string
+	State        string `gorm:"index"`
+	ResolvedByID *uint
+}
+
+type ReportResponse struct {
+	ID         uint    `json:"id"`
+	CreatedAt  string  `json:"createdAt"`
+	UpdatedAt  string  `json:"updatedAt"`
+	TargetType string  `json:"targetType"`
+	TargetID   uint    `json:"targetId"`
+	Reason     string  `json:"reason"`
+	State      string  `json:"state"`
+	Reporter   *Author `json:"reporter"`
+}
+
+type ReportResponseJson struct {
+	Report *ReportResponse `json:"report"`
+}
+
+type ReportsResponseJson struct {
+	Reports      []*ReportResponse `json:"reports"`
+	ReportsCount uint              `json:"reportsCount"`
+}
+
+// CreateReport files a report, hiding the target once it has collected
+// hideThreshold open reports, until a moderator resolves them. Users are
+// never hidden automatically. Reporting the same target twice returns the
+// first report.
+func (db *DB) CreateReport(reporterID uint, targetType string, targetID uint, reason string,
+	hideThreshold uint) *ReportResponseJson {
+	tx := db.Begin()
+	var report Report
+	// Create would fail with sql.ErrNoRows on a repeated report, so insert
+	// directly and look at RowsAffected.
+	now := gorm.NowFunc()
+	results := exec(tx, `INSERT INTO reports (created_at, updated_at, reporter_id, target_type, target_id, reason, state)
+		VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		now, now, reporterID, targetType, targetID, reason, ReportOpen)
+	tx.Where("reporter_id = ? AND target_type = ? AND target_id = ?", reporterID, targetType, targetID).First(&report)
+	if results.RowsAffected > 0 {
+		var open uint
+		tx.Model(&Report{}).Where(&Report{TargetType: targetType, TargetID: targetID, State: ReportOpen}).Count(&open)
+		if open >= hideThreshold {
+			setHidden(tx, targetType, targetID, true)
+		}
+	}
+	tx.Commit()
+
+	db.First(&report.Reporter, reporterID)
+	return db.PrepareReportResponse(&report)
+}
+
+// setHidden hides or shows an article or comment.
+func setHidden(tx *gorm.DB, targetType string, targetID uint, hidden bool) {
+	switch targetType {
+	case ReportArticle:
+		tx.Model(&Article{}).Where("id = ?", targetID).UpdateColumn("hidden", hidden)
+	case ReportComment:
+		tx.Model(&ArticleComment{}).Where("id = ?", targetID).UpdateColumn("hidden", hidden)
+	}
+}
+
+// ListReports returns the moderation queue, oldest first. ?state= selects
+// the state and defaults to open.
+func (db *DB) ListReports(queries url.Values) *ReportsResponseJson {
+	state := queries.Get("state")
+	if state == "" {
+		state = ReportOpen
+	}
+	sql := db.Preload("Reporter").Where(&Report{State: state}).Order("id asc")
+
+	limit, offset := pagination(queries)
+
+	var count uint
+	var reports []*Report
+	sql.Model(&Report{}).Count(&count)
+	sql.Offset(offset).Limit(limit).Find(&reports)
+
+	reportsResponse := []*ReportResponse{}
+	for _, report := range reports {
+		reportsResponse = append(reportsResponse, db.PrepareReport(report))
+	}
+
+	return &ReportsResponseJson{
+		Reports:      reportsResponse,
+		ReportsCount: count,
+	}
+}
+
+func (db *DB) GetReport(reportID uint) *Report {
+	var report Report
+	db.Preload("Reporter").Where("id = ?", reportID).First(&report)
+	return &report
+}
+
+// ResolveReport closes the report together with every other open report on
+// the same target. Actioning keeps an article or comment hidden; dismissing
+// shows it again unless an earlier report on it was actioned.
+func (db *DB) ResolveReport(report *Report, state string, moderatorID uint) *ReportResponseJson {
+	tx := db.Begin()
+	tx.Model(&Report{}).
+		Where("target_type = ? AND target_id = ? AND (state = ? OR id = ?)",
+			report.TargetType, report.TargetID, ReportOpen, report.ID).
+		Updates(map[string]interface{}{"state": state, "resolved_by_id": moderatorID})
+
+	if state == ReportActioned {
+		setHidden(tx, report.TargetType, report.TargetID, true)
+	} else {
+		var actioned uint
+		tx.Model(&Report{}).Where(&Report{TargetType: report.TargetType, TargetID: report.TargetID, State: ReportActioned}).
+			Count(&actioned)
+		if actioned == 0 {
+			setHidden(tx, report.TargetType, report.TargetID, false)
+		}
+	}
+	tx.Commit()
+
+	report.State = state
+	report.ResolvedByID = &moderatorID
+	return db.PrepareReportResponse(report)
+}
+
+func (db *DB) PrepareReportResponse(report *Report) *ReportResponseJson {
+	return &ReportResponseJson{
+		Report: db.PrepareReport(report),
+	}
+}
+
+func (db *DB) PrepareReport(report *Report) *ReportResponse {
+	return &ReportResponse{
+		ID:         report.ID,
+		CreatedAt:  report.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		UpdatedAt:  report.UpdatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		TargetType: report.TargetType,
+		TargetID:   report.TargetID,
+		Reason:     report.Reason,
+		State:      report.State,
+		Reporter: &Author{
+			ID:       report.Reporter.ID,
+			Username: report.Reporter.Username,
+			Bio:      report.Reporter.Bio,
+			Image:    report.Reporter.Image,
+		},
+	}
+}
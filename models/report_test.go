@@ -0,0 +1,53 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCreateReportTwice(t *testing.T) {
+	db := testDB(t)
+	author := createTestUser(t, db, "author")
+	article := createTestArticle(t, db, "Reported", "body", nil, author.ID)
+
+	for i := 1; i <= 3; i++ {
+		reporter := createTestUser(t, db, fmt.Sprintf("reporter%d", i))
+		report := db.CreateReport(reporter.ID, ReportArticle, article.ID, "spam", 2).Report
+		again := db.CreateReport(reporter.ID, ReportArticle, article.ID, "spam again", 2).Report
+		if report.ID == 0 || again.ID != report.ID || again.Reason != "spam" {
+			t.Fatalf("reporting twice: %+v then %+v, want the first report back", report, again)
+		}
+
+		var stored Article
+		db.First(&stored, article.ID)
+		if stored.Hidden != (i >= 2) {
+			t.Errorf("after %d reports hidden = %v", i, stored.Hidden)
+		}
+	}
+
+	var count uint
+	db.Model(&Report{}).Count(&count)
+	if count != 3 {
+		t.Errorf("%d reports stored, want 3", count)
+	}
+}
+
+func TestZeroIDsFindNothing(t *testing.T) {
+	db := testDB(t)
+	author := createTestUser(t, db, "author")
+	article := createTestArticle(t, db, "Commented", "body", nil, author.ID)
+	comment := createTestComment(t, db, article, author.ID, "first")
+	db.CreateReport(author.ID, ReportComment, comment.ID, "spam", 3)
+
+	if got := db.GetComment(0); got.ID != 0 {
+		t.Errorf("GetComment(0) found comment %d", got.ID)
+	}
+	if got := db.GetReport(0); got.ID != 0 {
+		t.Errorf("GetReport(0) found report %d", got.ID)
+	}
+
+	db.CreateAsset(author.ID, AvatarAsset, "image/png", 1, "avatars/1/a.png", nil)
+	if got := db.GetAsset(0, author.ID); got.ID != 0 {
+		t.Errorf("GetAsset(0) found asset %d", got.ID)
+	}
+}
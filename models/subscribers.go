@@ -0,0 +1,91 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+
+	"github.com/koyoyo/realworld-starter-kit/events"
+)
+
+// Subscribe registers the side effects of model operations on bus.
+func (db *DB) Subscribe(bus *events.Bus) {
+	bus.Subscribe("notifications", "comment.created", func(tx *gorm.DB, envelope *events.Envelope) error {
+		event := envelope.Event.(*events.CommentCreated)
+		return notify(tx, envelope, event.ArticleAuthorID, event.AuthorID, CommentNotification, "",
+			event.ArticleID, event.CommentID)
+	})
+	bus.Subscribe("notifications", "article.favorited", func(tx *gorm.DB, envelope *events.Envelope) error {
+		event := envelope.Event.(*events.ArticleFavorited)
+		return notify(tx, envelope, event.ArticleAuthorID, event.UserID, FavoriteNotification,
+			favoriteGroupKey(event.ArticleID), event.ArticleID, 0)
+	})
+	bus.Subscribe("notifications", "user.followed", func(tx *gorm.DB, envelope *events.Envelope) error {
+		event := envelope.Event.(*events.UserFollowed)
+		return notify(tx, envelope, event.FollowingID, event.FollowerID, FollowNotification, "follow", 0, 0)
+	})
+
+	bus.Subscribe("webhooks", "article.created", func(tx *gorm.DB, envelope *events.Envelope) error {
+		event := envelope.Event.(*events.ArticleCreated)
+		return enqueueArticleWebhooks(tx, ArticleCreatedEvent, event.ArticleID, event.AuthorID)
+	})
+	bus.Subscribe("webhooks", "article.updated", func(tx *gorm.DB, envelope *events.Envelope) error {
+		event := envelope.Event.(*events.ArticleUpdated)
+		return enqueueArticleWebhooks(tx, ArticleUpdatedEvent, event.ArticleID, event.AuthorID)
+	})
+	bus.Subscribe("webhooks", "article.deleted", func(tx *gorm.DB, envelope *events.Envelope) error {
+		event := envelope.Event.(*events.ArticleDeleted)
+		return enqueueWebhooks(tx, ArticleDeletedEvent, event.AuthorID, map[string]interface{}{
+			"article": map[string]string{
+				"slug":  event.Slug,
+				"title": event.Title,
+			},
+		})
+	})
+	bus.Subscribe("webhooks", "comment.created", func(tx *gorm.DB, envelope *events.Envelope) error {
+		event := envelope.Event.(*events.CommentCreated)
+
+		var comment ArticleComment
+		if err := tx.Unscoped().Preload("Author").Preload("Article").First(&comment, event.CommentID).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				// Purged in the meantime; there is nothing left to send.
+				return nil
+			}
+			return err
+		}
+		return enqueueWebhooks(tx, CommentCreatedEvent, event.ArticleAuthorID, map[string]interface{}{
+			"article": map[string]string{
+				"slug":  comment.Article.Slug,
+				"title": comment.Article.Title,
+			},
+			"comment": (&DB{tx}).PrepareComment(&comment),
+		})
+	})
+	bus.Subscribe("webhooks", "user.followed", func(tx *gorm.DB, envelope *events.Envelope) error {
+		event := envelope.Event.(*events.UserFollowed)
+
+		var users []*User
+		if err := tx.Where("id IN (?)", []uint{event.FollowerID, event.FollowingID}).Find(&users).Error; err != nil {
+			return err
+		}
+		usernames := map[uint]string{}
+		for _, user := range users {
+			usernames[user.ID] = user.Username
+		}
+		return enqueueWebhooks(tx, UserFollowedEvent, event.FollowingID, map[string]interface{}{
+			"follower":  map[string]string{"username": usernames[event.FollowerID]},
+			"following": map[string]string{"username": usernames[event.FollowingID]},
+		})
+	})
+}
+
+func enqueueArticleWebhooks(tx *gorm.DB, event string, articleID, authorID uint) error {
+	var article Article
+	if err := tx.Preload("Tag").Preload("Author").First(&article, articleID).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			// Deleted in the meantime; article.deleted will follow.
+			return nil
+		}
+		return err
+	}
+
+	return enqueueWebhooks(tx, event, authorID, (&DB{tx}).PrepareArticleResponse(&article))
+}
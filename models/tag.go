@@ -0,0 +1,212 @@
+package models
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type Tag struct {
+	ID          uint      `json:"-" gorm:"primary_key"`
+	CreatedAt   time.Time `json:"-"`
+	Name        string    `json:"name"`
+	Description string    `json:"description" gorm:"type:text"`
+}
+
+// TagFollow makes articles tagged TagID show up in UserID's feed.
+type TagFollow struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+
+	UserID uint `gorm:"unique_index:tag_follow"`
+	TagID  uint `gorm:"unique_index:tag_follow"`
+}
+
+type TagResponse struct {
+	Tags []string `json:"tags"`
+	// Following lists the tags of Tags the logged in user follows.
+	Following []string `json:"following,omitempty"`
+}
+
+type TagDetailResponse struct {
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	ArticlesCount uint   `json:"articlesCount"`
+	Following     bool   `json:"following"`
+}
+
+type TagDetailResponseJson struct {
+	Tag *TagDetailResponse `json:"tag"`
+}
+
+// NormalizeTag folds case and whitespace so that "Go", "go" and " go " are
+// the same tag. Inner runs of whitespace become a single hyphen.
+func NormalizeTag(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), "-")
+}
+
+// findOrCreateTags returns the tags named by tagList after normalization,
+// without duplicates or empty names.
+func findOrCreateTags(tx *gorm.DB, tagList []string) []Tag {
+	tags := []Tag{}
+	seen := map[string]bool{}
+	for _, tagName := range tagList {
+		name := NormalizeTag(tagName)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		var tag Tag
+		tx.Where(Tag{Name: name}).FirstOrCreate(&tag)
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// deleteOrphanTags removes tags no article refers to and nobody follows.
+// Soft-deleted articles still hold on to their tags so they can be restored
+// intact.
+func deleteOrphanTags(tx *gorm.DB) {
+	exec(tx, `DELETE FROM tags WHERE NOT EXISTS (
+		SELECT 1 FROM article_tags WHERE article_tags.tag_id = tags.id
+	) AND NOT EXISTS (
+		SELECT 1 FROM tag_follows WHERE tag_follows.tag_id = tags.id
+	)`)
+}
+
+// ListTags returns tag names by how many live articles use them. limit and
+// prefix (for autocomplete) are read from queries.
+func (db *DB) ListTags(queries url.Values) *TagResponse {
+	sql := db.Table("tags").
+		Select("tags.name").
+		Joins("JOIN article_tags ON article_tags.tag_id = tags.id").
+		Joins("JOIN articles ON articles.id = article_tags.article_id AND articles.deleted_at IS NULL").
+		Group("tags.id, tags.name").
+		Order("COUNT(articles.id) desc, tags.name asc")
+
+	if prefix := NormalizeTag(queries.Get("prefix")); prefix != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)
+		sql = sql.Where("tags.name LIKE ?", escaped+"%")
+	}
+
+	if limitStr := queries.Get("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil {
+			sql = sql.Limit(limit)
+		}
+	}
+
+	tags := []string{}
+	sql.Pluck("tags.name", &tags)
+	return &TagResponse{
+		Tags: tags,
+	}
+}
+
+func (db *DB) ListTagsWithUser(queries url.Values, userID uint) *TagResponse {
+	tags := db.ListTags(queries)
+	if len(tags.Tags) > 0 {
+		db.Table("tags").
+			Joins("JOIN tag_follows ON tag_follows.tag_id = tags.id").
+			Where("tag_follows.user_id = ? AND tags.name IN (?)", userID, tags.Tags).
+			Order("tags.name asc").
+			Pluck("tags.name", &tags.Following)
+	}
+	return tags
+}
+
+func (db *DB) GetTag(name string) *Tag {
+	var tag Tag
+	db.Where(&Tag{Name: NormalizeTag(name)}).First(&tag)
+	return &tag
+}
+
+func (db *DB) UpdateTag(tag *Tag, description string) *TagDetailResponseJson {
+	tag.Description = description
+	db.Model(tag).Update("Description", description)
+	return db.PrepareTagResponse(tag)
+}
+
+func (db *DB) IsFollowingTag(tagID, userID uint) bool {
+	var count uint
+	db.Model(&TagFollow{}).Where(&TagFollow{UserID: userID, TagID: tagID}).Count(&count)
+	return count > 0
+}
+
+// FollowedTagNames lists the names of the tags userID follows.
+func (db *DB) FollowedTagNames(userID uint) []string {
+	names := []string{}
+	db.Table("tags").
+		Joins("JOIN tag_follows ON tag_follows.tag_id = tags.id").
+		Where("tag_follows.user_id = ?", userID).
+		Pluck("tags.name", &names)
+	return names
+}
+
+func (db *DB) FollowTag(tagID, userID uint) {
+	tagFollow := TagFollow{}
+	db.FirstOrCreate(&tagFollow, TagFollow{UserID: userID, TagID: tagID})
+}
+
+func (db *DB) UnfollowTag(tagID, userID uint) {
+	db.Where(&TagFollow{UserID: userID, TagID: tagID}).Delete(TagFollow{})
+}
+
+func (db *DB) PrepareTagResponse(tag *Tag) *TagDetailResponseJson {
+	var count uint
+	db.Table("article_tags").
+		Joins("JOIN articles ON articles.id = article_tags.article_id AND articles.deleted_at IS NULL").
+		Where("article_tags.tag_id = ?", tag.ID).
+		Count(&count)
+
+	return &TagDetailResponseJson{
+		Tag: &TagDetailResponse{
+			Name:          tag.Name,
+			Description:   tag.Description,
+			ArticlesCount: count,
+		},
+	}
+}
+
+// normalizeTags merges tags whose names only differ by case or whitespace,
+// then enforces unique names.
+func normalizeTags(tx *gorm.DB) error {
+	var tags []*Tag
+	if err := tx.Order("id").Find(&tags).Error; err != nil {
+		return err
+	}
+
+	keepers := map[string]*Tag{}
+	for _, tag := range tags {
+		name := NormalizeTag(tag.Name)
+		keeper, ok := keepers[name]
+		if !ok {
+			keepers[name] = tag
+			if name != tag.Name {
+				if err := tx.Model(tag).UpdateColumn("name", name).Error; err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		// Point the duplicate's articles at the keeper, skipping articles
+		// that already have both.
+		if err := exec(tx, `INSERT INTO article_tags (article_id, tag_id)
+			SELECT article_id, ? FROM article_tags WHERE tag_id = ?
+			ON CONFLICT DO NOTHING`, keeper.ID, tag.ID).Error; err != nil {
+			return err
+		}
+		if err := exec(tx, "DELETE FROM article_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(tag).Error; err != nil {
+			return err
+		}
+	}
+
+	return exec(tx, "CREATE UNIQUE INDEX IF NOT EXISTS uix_tags_name ON tags (name)").Error
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestListTagsWithUser(t *testing.T) {
+	db := testDB(t)
+	author := createTestUser(t, db, "author")
+	reader := createTestUser(t, db, "reader")
+	createTestArticle(t, db, "Tagged", "body", []string{"go", "postgres", "testing"}, author.ID)
+	db.FollowTag(db.GetTag("postgres").ID, reader.ID)
+	db.FollowTag(db.GetTag("go").ID, reader.ID)
+
+	tags := db.ListTagsWithUser(url.Values{}, reader.ID)
+	if len(tags.Tags) != 3 || !reflect.DeepEqual(tags.Following, []string{"go", "postgres"}) {
+		t.Errorf("tags %v following %v, want 3 tags following [go postgres]", tags.Tags, tags.Following)
+	}
+
+	if tags := db.ListTagsWithUser(url.Values{"prefix": {"te"}}, reader.ID); len(tags.Following) != 0 {
+		t.Errorf("following %v outside the listed tags %v", tags.Following, tags.Tags)
+	}
+	if tags := db.ListTags(url.Values{}); tags.Following != nil {
+		t.Errorf("anonymous list has following %v", tags.Following)
+	}
+}
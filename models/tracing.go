@@ -0,0 +1,109 @@
+package models
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	contextKey = "otel:context"
+	spanKey    = "otel:span"
+)
+
+var tracer = otel.Tracer("github.com/koyoyo/realworld-starter-kit/models")
+
+// WithContext returns a DB whose queries are traced as children of the span
+// carried by ctx.
+func (db *DB) WithContext(ctx context.Context) *DB {
+	return &DB{db.Set(contextKey, ctx)}
+}
+
+func (db *DB) context() context.Context {
+	if ctx, ok := db.Get(contextKey); ok {
+		return ctx.(context.Context)
+	}
+	return context.Background()
+}
+
+// startSpan opens a span around a model operation so that the queries it
+// issues are grouped together in the trace.
+func (db *DB) startSpan(name string) (*DB, trace.Span) {
+	ctx, span := tracer.Start(db.context(), name)
+	return db.WithContext(ctx), span
+}
+
+// RegisterTracing adds GORM callbacks that open a span for every query. Raw
+// statements run through Exec skip the callbacks; exec traces those.
+func RegisterTracing(db *gorm.DB) {
+	db.Callback().Create().Before("gorm:create").Register("otel:before_create", beforeQuery("gorm.create"))
+	db.Callback().Create().After("gorm:create").Register("otel:after_create", afterQuery)
+	db.Callback().Query().Before("gorm:query").Register("otel:before_query", beforeQuery("gorm.query"))
+	db.Callback().Query().After("gorm:query").Register("otel:after_query", afterQuery)
+	db.Callback().Update().Before("gorm:update").Register("otel:before_update", beforeQuery("gorm.update"))
+	db.Callback().Update().After("gorm:update").Register("otel:after_update", afterQuery)
+	db.Callback().Delete().Before("gorm:delete").Register("otel:before_delete", beforeQuery("gorm.delete"))
+	db.Callback().Delete().After("gorm:delete").Register("otel:after_delete", afterQuery)
+	db.Callback().RowQuery().Before("gorm:row_query").Register("otel:before_row_query", beforeQuery("gorm.row_query"))
+	db.Callback().RowQuery().After("gorm:row_query").Register("otel:after_row_query", afterQuery)
+}
+
+func beforeQuery(name string) func(*gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		ctx := context.Background()
+		if value, ok := scope.Get(contextKey); ok {
+			ctx = value.(context.Context)
+		}
+
+		_, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+		scope.Set(spanKey, span)
+	}
+}
+
+// exec runs a raw statement with tx.Exec inside a span like the ones
+// RegisterTracing opens for every other query.
+func exec(tx *gorm.DB, sql string, values ...interface{}) *gorm.DB {
+	ctx := context.Background()
+	if value, ok := tx.Get(contextKey); ok {
+		ctx = value.(context.Context)
+	}
+	_, span := tracer.Start(ctx, "gorm.exec", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	results := tx.Exec(sql, values...)
+	span.SetAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.statement", sql),
+		attribute.Int64("db.rows_affected", results.RowsAffected),
+	)
+	if results.Error != nil {
+		span.RecordError(results.Error)
+		span.SetStatus(codes.Error, results.Error.Error())
+	}
+	return results
+}
+
+func afterQuery(scope *gorm.Scope) {
+	value, ok := scope.Get(spanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.sql.table", scope.TableName()),
+		attribute.String("db.statement", scope.SQL),
+		attribute.Int64("db.rows_affected", scope.DB().RowsAffected),
+	)
+
+	if err := scope.DB().Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
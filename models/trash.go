@@ -0,0 +1,235 @@
+package models
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+var ErrArticleDeleted = errors.New("the article of this comment is deleted")
+
+type TrashedArticle struct {
+	*ArticleResponse
+	DeletedAt string `json:"deletedAt"`
+	PurgeAt   string `json:"purgeAt"`
+}
+
+type TrashedComment struct {
+	*CommentResponse
+	ArticleSlug string `json:"articleSlug"`
+	DeletedAt   string `json:"deletedAt"`
+	PurgeAt     string `json:"purgeAt"`
+}
+
+type TrashResponseJson struct {
+	Articles []*TrashedArticle `json:"articles"`
+	Comments []*TrashedComment `json:"comments"`
+}
+
+// trashEntry is one row of the trash, an article or a comment.
+type trashEntry struct {
+	Kind string
+	ID   uint
+}
+
+// ListTrash lists the articles and comments userID deleted, most recent
+// first, with the time they will be purged after retention. Both kinds are
+// paginated together, so a page holds at most limit entries in all. Comments
+// deleted along with their article are restored with it and aren't listed on
+// their own.
+func (db *DB) ListTrash(queries url.Values, userID uint, retention time.Duration) *TrashResponseJson {
+	limit, offset := pagination(queries)
+
+	var entries []trashEntry
+	db.Raw(`SELECT 'article' AS kind, id, deleted_at FROM articles
+			WHERE author_id = ? AND deleted_at IS NOT NULL
+		UNION ALL
+		SELECT 'comment' AS kind, article_comments.id, article_comments.deleted_at FROM article_comments
+			JOIN articles ON articles.id = article_comments.article_id AND articles.deleted_at IS NULL
+			WHERE article_comments.author_id = ? AND article_comments.deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, kind, id DESC
+		OFFSET ? LIMIT ?`, userID, userID, offset, limit).Scan(&entries)
+
+	var articleIDs, commentIDs []uint
+	for _, entry := range entries {
+		if entry.Kind == "article" {
+			articleIDs = append(articleIDs, entry.ID)
+		} else {
+			commentIDs = append(commentIDs, entry.ID)
+		}
+	}
+
+	var articles []*Article
+	if len(articleIDs) > 0 {
+		db.Unscoped().Preload("Tag").Preload("Author").Where("id IN (?)", articleIDs).
+			Order("deleted_at desc, id desc").Find(&articles)
+	}
+
+	var comments []*ArticleComment
+	if len(commentIDs) > 0 {
+		db.Unscoped().Preload("Author").Preload("Article").Where("id IN (?)", commentIDs).
+			Order("deleted_at desc, id desc").Find(&comments)
+	}
+
+	trash := &TrashResponseJson{
+		Articles: []*TrashedArticle{},
+		Comments: []*TrashedComment{},
+	}
+	for _, article := range articles {
+		trash.Articles = append(trash.Articles, &TrashedArticle{
+			ArticleResponse: db.PrepareArticle(article),
+			DeletedAt:       article.DeletedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+			PurgeAt:         article.DeletedAt.Add(retention).UTC().Format("2006-01-02T15:04:05.000Z"),
+		})
+	}
+	for _, comment := range comments {
+		trash.Comments = append(trash.Comments, &TrashedComment{
+			CommentResponse: db.PrepareComment(comment),
+			ArticleSlug:     comment.Article.Slug,
+			DeletedAt:       comment.DeletedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+			PurgeAt:         comment.DeletedAt.Add(retention).UTC().Format("2006-01-02T15:04:05.000Z"),
+		})
+	}
+	return trash
+}
+
+// GetTrashedArticle returns the most recently deleted article of authorID
+// with this slug.
+func (db *DB) GetTrashedArticle(slug string, authorID uint) *Article {
+	var article Article
+	db.Unscoped().Preload("Tag").Preload("Author").
+		Where("slug = ? AND author_id = ? AND deleted_at IS NOT NULL", slug, authorID).
+		Order("deleted_at desc").First(&article)
+	return &article
+}
+
+func (db *DB) GetTrashedComment(commentID, authorID uint) *ArticleComment {
+	var comment ArticleComment
+	db.Unscoped().Preload("Author").
+		Where("id = ? AND author_id = ? AND deleted_at IS NOT NULL", commentID, authorID).
+		First(&comment)
+	return &comment
+}
+
+// RestoreArticle takes the article out of the trash together with the
+// comments that were deleted with it. When a live article has taken its slug
+// in the meantime, it gets a unique one instead.
+func (db *DB) RestoreArticle(article *Article) (response *ArticleResponseJson, err error) {
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var taken uint
+	if err = tx.Model(&Article{}).Where("slug = ? AND id <> ?", article.Slug, article.ID).Count(&taken).Error; err != nil {
+		return
+	}
+	slug := article.Slug
+	if taken > 0 {
+		slug = uniqueSlug(tx, article.Slug)
+		if err = tx.Unscoped().Model(&Article{}).Where("id = ?", article.ID).UpdateColumn("slug", slug).Error; err != nil {
+			return
+		}
+	}
+	err = tx.Unscoped().Model(&ArticleComment{}).
+		Where("article_id = ? AND deleted_at = ?", article.ID, article.DeletedAt).
+		UpdateColumn("deleted_at", nil).Error
+	if err != nil {
+		return
+	}
+	if err = tx.Unscoped().Model(&Article{}).Where("id = ?", article.ID).UpdateColumn("deleted_at", nil).Error; err != nil {
+		return
+	}
+	if err = tx.Commit().Error; err != nil {
+		return
+	}
+
+	article.Slug = slug
+	article.DeletedAt = nil
+	return db.PrepareArticleResponse(article), nil
+}
+
+// RestoreComment takes the comment out of the trash. It fails with
+// ErrArticleDeleted while the comment's article is in the trash.
+func (db *DB) RestoreComment(comment *ArticleComment) (*CommentResponseJson, error) {
+	var count uint
+	db.Model(&Article{}).Where("id = ?", comment.ArticleID).Count(&count)
+	if count == 0 {
+		return nil, ErrArticleDeleted
+	}
+
+	tx := db.Begin()
+	tx.Unscoped().Model(&ArticleComment{}).Where("id = ?", comment.ID).UpdateColumn("deleted_at", nil)
+	tx.Model(&Article{}).Where("id = ?", comment.ArticleID).
+		UpdateColumn("comments_count", gorm.Expr("comments_count + 1"))
+	tx.Commit()
+
+	comment.DeletedAt = nil
+	return db.PrepareCommentResponse(comment), nil
+}
+
+// PurgeTrash hard-deletes the articles and comments deleted before cutoff.
+func (db *DB) PurgeTrash(cutoff time.Time) (articles, comments int64, err error) {
+	db, span := db.startSpan("models.PurgeTrash")
+	defer span.End()
+
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var articleIDs []uint
+	if err = tx.Unscoped().Model(&Article{}).Where("deleted_at < ?", cutoff).Pluck("id", &articleIDs).Error; err != nil {
+		return
+	}
+
+	if articles, err = purgeArticles(tx, articleIDs); err != nil {
+		return
+	}
+
+	results := tx.Unscoped().Where("deleted_at < ?", cutoff).Delete(&ArticleComment{})
+	if err = results.Error; err != nil {
+		return
+	}
+	comments = results.RowsAffected
+
+	err = tx.Commit().Error
+	return
+}
+
+// purgeArticles hard-deletes the articles along with their comments,
+// favorites, bookmarks, reading list entries, tags and recommendations.
+func purgeArticles(tx *gorm.DB, articleIDs []uint) (int64, error) {
+	if len(articleIDs) == 0 {
+		return 0, nil
+	}
+
+	cascades := []string{
+		"DELETE FROM article_comments WHERE article_id IN (?)",
+		"DELETE FROM article_favorites WHERE article_id IN (?)",
+		"DELETE FROM bookmarks WHERE article_id IN (?)",
+		"DELETE FROM reading_list_items WHERE article_id IN (?)",
+		"DELETE FROM article_tags WHERE article_id IN (?)",
+		"DELETE FROM related_articles WHERE article_id IN (?)",
+		"DELETE FROM related_articles WHERE related_id IN (?)",
+		"DELETE FROM recommendations WHERE article_id IN (?)",
+	}
+	for _, cascade := range cascades {
+		if err := exec(tx, cascade, articleIDs).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	results := tx.Unscoped().Where("id IN (?)", articleIDs).Delete(&Article{})
+	if results.Error != nil {
+		return 0, results.Error
+	}
+	deleteOrphanTags(tx)
+	return results.RowsAffected, nil
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRestoreArticleTakenSlug(t *testing.T) {
+	db := testDB(t)
+	author := createTestUser(t, db, "author")
+
+	trashed := createTestArticle(t, db, "Same title", "first", nil, author.ID)
+	if err := db.DeleteArticle(trashed); err != nil {
+		t.Fatal(err)
+	}
+	live := createTestArticle(t, db, "Same title", "second", nil, author.ID)
+
+	response, err := db.RestoreArticle(db.GetTrashedArticle(trashed.Slug, author.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := response.Article
+	if restored.Slug == live.Slug {
+		t.Fatalf("restored article kept the slug %q of a live article", live.Slug)
+	}
+	if got := db.GetArticleFromSlug(restored.Slug); got.ID != trashed.ID {
+		t.Errorf("%s is article %d, want the restored %d", restored.Slug, got.ID, trashed.ID)
+	}
+	if got := db.GetArticleFromSlug(live.Slug); got.Body != "second" {
+		t.Errorf("%s has body %q, want the live article's", live.Slug, got.Body)
+	}
+}
+
+func TestArticleSlugsStayUnique(t *testing.T) {
+	db := testDB(t)
+	author := createTestUser(t, db, "author")
+	first := createTestArticle(t, db, "Same title", "first", nil, author.ID)
+	if err := db.DeleteArticle(first); err != nil {
+		t.Fatal(err)
+	}
+	second := createTestArticle(t, db, "Same title", "second", nil, author.ID)
+	if second.Slug == first.Slug {
+		t.Errorf("new article took the slug %q of a trashed one", first.Slug)
+	}
+
+	other := createTestArticle(t, db, "Other title", "third", nil, author.ID)
+	response, err := db.UpdateArticle(other, "Same title", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slug := response.Article.Slug; slug == first.Slug || slug == second.Slug {
+		t.Errorf("renamed article took the slug %q", slug)
+	}
+
+	response, err = db.UpdateArticle(second, "Same title", "", "edited", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Article.Slug != second.Slug {
+		t.Errorf("slug changed from %q to %q without a new title", second.Slug, response.Article.Slug)
+	}
+}
+
+// Articles and comments share one page, most recently deleted first.
+func TestListTrashPaginatesTogether(t *testing.T) {
+	db := testDB(t)
+	author := createTestUser(t, db, "author")
+	live := createTestArticle(t, db, "Live", "body", nil, author.ID)
+	for i := 0; i < 3; i++ {
+		comment := createTestComment(t, db, live, author.ID, "comment")
+		db.DeleteArticleComment(db.GetComment(comment.ID))
+		article := createTestArticle(t, db, fmt.Sprintf("Trashed %d", i), "body", nil, author.ID)
+		if err := db.DeleteArticle(article); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := 0
+	for offset := 0; offset < 6; offset += 2 {
+		queries := url.Values{"limit": {"2"}, "offset": {fmt.Sprint(offset)}}
+		trash := db.ListTrash(queries, author.ID, time.Hour)
+		if n := len(trash.Articles) + len(trash.Comments); n != 2 {
+			t.Errorf("page at offset %d has %d entries, want 2", offset, n)
+		}
+		seen += len(trash.Articles) + len(trash.Comments)
+	}
+	if seen != 6 {
+		t.Errorf("listed %d entries, want 6", seen)
+	}
+}
+
+func TestTrendingIgnoresDeletedComments(t *testing.T) {
+	db := testDB(t)
+	author := createTestUser(t, db, "author")
+	article := createTestArticle(t, db, "Trending", "body", nil, author.ID)
+	comment := createTestComment(t, db, article, author.ID, "soon deleted")
+	db.DeleteArticleComment(db.GetComment(comment.ID))
+
+	if _, err := db.RefreshTrendingScores(24 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	var stored Article
+	db.First(&stored, article.ID)
+	if stored.TrendingScore != 0 {
+		t.Errorf("trending score = %v, want 0 with only a deleted comment", stored.TrendingScore)
+	}
+}
@@ -1,11 +1,11 @@
 package models
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"time"
 
-	"github.com/spf13/viper"
-
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/jinzhu/gorm"
 	"golang.org/x/crypto/bcrypt"
@@ -19,6 +19,11 @@ type User struct {
 	Bio      string  `json:"bio"`
 	Image    *string `json:"image"`
 	Token    string  `gorm:"-" json:"token"`
+
+	FeedToken *string `gorm:"unique_index" json:"-"`
+	Admin     bool    `json:"-"`
+	// TokensRevokedAt invalidates every JWT issued up to that second.
+	TokensRevokedAt *time.Time `json:"-"`
 }
 
 type UserResponse struct {
@@ -79,6 +84,12 @@ func (db *DB) GetUserFromEmail(email string) *UserResponse {
 	}
 }
 
+func (db *DB) GetUserFromID(userID uint) *User {
+	user := User{}
+	db.First(&user, userID)
+	return &user
+}
+
 func (db *DB) GetUserFromUsername(username string) *UserResponse {
 	user := User{}
 	db.Where(&User{Username: username}).First(&user)
@@ -87,11 +98,16 @@ func (db *DB) GetUserFromUsername(username string) *UserResponse {
 	}
 }
 
-func GenerateToken(username string, userID uint) string {
-	mySigningKey := []byte(viper.GetString("JWT_SIGNED_KEY"))
+func GenerateToken(key []byte, username string, userID uint) string {
+	return GenerateTokenWithTTL(key, username, userID, 24*time.Hour)
+}
+
+// GenerateTokenWithTTL signs a token that expires after ttl.
+func GenerateTokenWithTTL(key []byte, username string, userID uint, ttl time.Duration) string {
 	claims := MyCustomClaims{
 		jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
+			ExpiresAt: time.Now().Add(ttl).Unix(),
+			IssuedAt:  time.Now().Unix(),
 			Issuer:    "KoYoYo",
 		},
 		username,
@@ -99,15 +115,15 @@ func GenerateToken(username string, userID uint) string {
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	ss, err := token.SignedString(mySigningKey)
+	ss, err := token.SignedString(key)
 	if err != nil {
 		panic(fmt.Errorf("JWT Signed String Error: %s", err))
 	}
 	return ss
 }
 
-func (user *User) NewToken() {
-	user.Token = GenerateToken(user.Username, user.ID)
+func (user *User) NewToken(key []byte) {
+	user.Token = GenerateToken(key, user.Username, user.ID)
 }
 
 func (user *User) CheckPassword(password string) bool {
@@ -116,3 +132,63 @@ func (user *User) CheckPassword(password string) bool {
 	}
 	return true
 }
+
+// GetFeedToken returns the secret token of the user's private feed, creating
+// one on first use.
+func (db *DB) GetFeedToken(user *User) string {
+	if user.FeedToken != nil {
+		return *user.FeedToken
+	}
+	return db.ResetFeedToken(user)
+}
+
+// ResetFeedToken replaces the user's feed token, revoking the old feed URL.
+func (db *DB) ResetFeedToken(user *User) string {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Errorf("Feed token err: %s", err))
+	}
+
+	token := hex.EncodeToString(buf)
+	db.Model(user).Update("FeedToken", token)
+	user.FeedToken = &token
+	return token
+}
+
+func (db *DB) GetUserFromFeedToken(token string) *User {
+	user := User{}
+	if token != "" {
+		db.Where("feed_token = ?", token).First(&user)
+	}
+	return &user
+}
+
+func (db *DB) IsAdmin(userID uint) bool {
+	var count uint
+	db.Model(&User{}).Where("id = ? AND admin = ?", userID, true).Count(&count)
+	return count > 0
+}
+
+// SetAdmin grants or withdraws the admin role.
+func (db *DB) SetAdmin(user *User, admin bool) {
+	db.Model(user).UpdateColumn("admin", admin)
+	user.Admin = admin
+}
+
+// RevokeTokens invalidates the user's JWTs and private feed URL.
+func (db *DB) RevokeTokens(user *User) {
+	now := time.Now()
+	db.Model(user).UpdateColumns(map[string]interface{}{"tokens_revoked_at": now, "feed_token": nil})
+	user.TokensRevokedAt = &now
+	user.FeedToken = nil
+}
+
+// TokenRevoked reports whether a JWT of userID issued at issuedAt (a Unix
+// time) may no longer be used, because it was revoked or the account is gone.
+func (db *DB) TokenRevoked(userID uint, issuedAt int64) bool {
+	var user User
+	if db.Select("id, tokens_revoked_at").First(&user, userID).RecordNotFound() {
+		return true
+	}
+	return user.TokensRevokedAt != nil && issuedAt <= user.TokensRevokedAt.Unix()
+}
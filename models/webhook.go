@@ -0,0 +1,359 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	ArticleCreatedEvent = "article.created"
+	ArticleUpdatedEvent = "article.updated"
+	ArticleDeletedEvent = "article.deleted"
+	CommentCreatedEvent = "comment.created"
+	UserFollowedEvent   = "user.followed"
+)
+
+var WebhookEvents = []string{
+	ArticleCreatedEvent,
+	ArticleUpdatedEvent,
+	ArticleDeletedEvent,
+	CommentCreatedEvent,
+	UserFollowedEvent,
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+const (
+	webhookMaxAttempts = 10
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+	// webhookLease keeps a claimed delivery from being picked up again by
+	// another worker while it is being sent.
+	webhookLease = 2 * time.Minute
+)
+
+// Webhook receives the events about its owner's content, or every event when
+// Global is set (admins only). Events is a comma separated filter; empty
+// means every event.
+type Webhook struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	OwnerID uint `gorm:"index"`
+	Owner   User
+	Global  bool
+	URL     string
+	Secret  string `json:"-"`
+	Events  string
+	Active  bool
+}
+
+// WebhookDelivery is one queued POST of an event to a webhook.
+type WebhookDelivery struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	WebhookID      uint `gorm:"index"`
+	Webhook        Webhook
+	Event          string
+	Payload        string `gorm:"type:text"`
+	Status         string `gorm:"index"`
+	Attempts       int
+	NextAttemptAt  time.Time `gorm:"index"`
+	LastStatusCode int
+	LastError      string `gorm:"type:text"`
+	DeliveredAt    *time.Time
+}
+
+type WebhookResponse struct {
+	ID        uint     `json:"id"`
+	CreatedAt string   `json:"createdAt"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Global    bool     `json:"global"`
+	Active    bool     `json:"active"`
+	Secret    string   `json:"secret,omitempty"`
+}
+
+type WebhookResponseJson struct {
+	Webhook *WebhookResponse `json:"webhook"`
+}
+
+type WebhooksResponseJson struct {
+	Webhooks []*WebhookResponse `json:"webhooks"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             uint    `json:"id"`
+	CreatedAt      string  `json:"createdAt"`
+	Event          string  `json:"event"`
+	Status         string  `json:"status"`
+	Attempts       int     `json:"attempts"`
+	NextAttemptAt  *string `json:"nextAttemptAt"`
+	LastStatusCode int     `json:"lastStatusCode"`
+	LastError      string  `json:"lastError"`
+	DeliveredAt    *string `json:"deliveredAt"`
+}
+
+type WebhookDeliveriesResponseJson struct {
+	Deliveries      []*WebhookDeliveryResponse `json:"deliveries"`
+	DeliveriesCount uint                       `json:"deliveriesCount"`
+}
+
+// webhookPayload is the body POSTed to webhooks.
+type webhookPayload struct {
+	Event     string      `json:"event"`
+	CreatedAt string      `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// Accepts reports whether the webhook's filter lets event through.
+func (webhook *Webhook) Accepts(event string) bool {
+	if webhook.Events == "" {
+		return true
+	}
+
+	for _, e := range strings.Split(webhook.Events, ",") {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueueWebhooks queues event for every active webhook of ownerID and every
+// global webhook. When the change runs in a transaction, pass that
+// transaction so the deliveries only exist if the change does.
+func enqueueWebhooks(tx *gorm.DB, event string, ownerID uint, data interface{}) error {
+	var webhooks []*Webhook
+	if err := tx.Where("active = ? AND (owner_id = ? OR global = ?)", true, ownerID, true).Find(&webhooks).Error; err != nil {
+		return err
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(&webhookPayload{
+		Event:     event,
+		CreatedAt: time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Accepts(event) {
+			continue
+		}
+
+		err := tx.Create(&WebhookDelivery{
+			WebhookID:     webhook.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        DeliveryPending,
+			NextAttemptAt: time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) CreateWebhook(ownerID uint, webhookURL string, events []string, global bool) *WebhookResponseJson {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Errorf("Webhook secret err: %s", err))
+	}
+
+	webhook := Webhook{
+		OwnerID: ownerID,
+		Global:  global,
+		URL:     webhookURL,
+		Secret:  hex.EncodeToString(buf),
+		Events:  strings.Join(events, ","),
+		Active:  true,
+	}
+	db.Create(&webhook)
+
+	// The secret is only ever shown once, when the webhook is created.
+	response := db.PrepareWebhookResponse(&webhook)
+	response.Webhook.Secret = webhook.Secret
+	return response
+}
+
+func (db *DB) GetWebhook(webhookID, ownerID uint) *Webhook {
+	var webhook Webhook
+	db.Where(&Webhook{ID: webhookID, OwnerID: ownerID}).First(&webhook)
+	return &webhook
+}
+
+func (db *DB) ListWebhooks(ownerID uint) *WebhooksResponseJson {
+	var webhooks []*Webhook
+	db.Where(&Webhook{OwnerID: ownerID}).Order("ID desc").Find(&webhooks)
+
+	webhooksResponse := []*WebhookResponse{}
+	for _, webhook := range webhooks {
+		webhooksResponse = append(webhooksResponse, db.PrepareWebhookResponse(webhook).Webhook)
+	}
+
+	return &WebhooksResponseJson{
+		Webhooks: webhooksResponse,
+	}
+}
+
+func (db *DB) DeleteWebhook(webhook *Webhook) {
+	tx := db.Begin()
+	tx.Where(&WebhookDelivery{WebhookID: webhook.ID}).Delete(WebhookDelivery{})
+	tx.Delete(webhook)
+	tx.Commit()
+}
+
+func (db *DB) ListWebhookDeliveries(queries url.Values, webhookID uint) *WebhookDeliveriesResponseJson {
+	sql := db.Model(&WebhookDelivery{}).Where(&WebhookDelivery{WebhookID: webhookID})
+	if status, ok := queries["status"]; ok {
+		sql = sql.Where(&WebhookDelivery{Status: status[0]})
+	}
+
+	limit, offset := pagination(queries)
+
+	var count uint
+	var deliveries []*WebhookDelivery
+	sql.Count(&count)
+	sql.Order("ID desc").Offset(offset).Limit(limit).Find(&deliveries)
+
+	deliveriesResponse := []*WebhookDeliveryResponse{}
+	for _, delivery := range deliveries {
+		deliveriesResponse = append(deliveriesResponse, db.PrepareWebhookDelivery(delivery))
+	}
+
+	return &WebhookDeliveriesResponseJson{
+		Deliveries:      deliveriesResponse,
+		DeliveriesCount: count,
+	}
+}
+
+// ClaimWebhookDeliveries leases up to limit due deliveries to the caller.
+// SKIP LOCKED lets several instances drain the queue without sending the
+// same delivery twice.
+func (db *DB) ClaimWebhookDeliveries(limit int) []*WebhookDelivery {
+	rows, err := db.Raw(`UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED
+		) RETURNING id`, time.Now().Add(webhookLease), DeliveryPending, time.Now(), limit).Rows()
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var ids []uint
+	for rows.Next() {
+		var id uint
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	var deliveries []*WebhookDelivery
+	if len(ids) > 0 {
+		db.Preload("Webhook").Where("id IN (?)", ids).Order("ID").Find(&deliveries)
+	}
+	return deliveries
+}
+
+// RecordWebhookAttempt stores the outcome of sending delivery. Failures are
+// retried with exponential backoff until webhookMaxAttempts is reached.
+func (db *DB) RecordWebhookAttempt(delivery *WebhookDelivery, statusCode int, sendErr error) {
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = ""
+
+	if sendErr == nil && statusCode >= 200 && statusCode < 300 {
+		now := time.Now()
+		delivery.Status = DeliverySucceeded
+		delivery.DeliveredAt = &now
+	} else {
+		if sendErr != nil {
+			delivery.LastError = sendErr.Error()
+		} else {
+			delivery.LastError = fmt.Sprintf("unexpected status %d", statusCode)
+		}
+
+		if delivery.Attempts >= webhookMaxAttempts {
+			delivery.Status = DeliveryFailed
+		} else {
+			backoff := webhookBaseBackoff << uint(delivery.Attempts-1)
+			if backoff > webhookMaxBackoff || backoff <= 0 {
+				backoff = webhookMaxBackoff
+			}
+			delivery.NextAttemptAt = time.Now().Add(backoff)
+		}
+	}
+
+	db.Model(delivery).Updates(map[string]interface{}{
+		"attempts":         delivery.Attempts,
+		"last_status_code": delivery.LastStatusCode,
+		"last_error":       delivery.LastError,
+		"status":           delivery.Status,
+		"next_attempt_at":  delivery.NextAttemptAt,
+		"delivered_at":     delivery.DeliveredAt,
+	})
+}
+
+func (db *DB) PrepareWebhookResponse(webhook *Webhook) *WebhookResponseJson {
+	events := []string{}
+	if webhook.Events != "" {
+		events = strings.Split(webhook.Events, ",")
+	}
+
+	return &WebhookResponseJson{
+		Webhook: &WebhookResponse{
+			ID:        webhook.ID,
+			CreatedAt: webhook.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+			URL:       webhook.URL,
+			Events:    events,
+			Global:    webhook.Global,
+			Active:    webhook.Active,
+		},
+	}
+}
+
+func (db *DB) PrepareWebhookDelivery(delivery *WebhookDelivery) *WebhookDeliveryResponse {
+	deliveryResponse := &WebhookDeliveryResponse{
+		ID:             delivery.ID,
+		CreatedAt:      delivery.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		Event:          delivery.Event,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+	}
+
+	if delivery.Status == DeliveryPending {
+		nextAttemptAt := delivery.NextAttemptAt.UTC().Format("2006-01-02T15:04:05.000Z")
+		deliveryResponse.NextAttemptAt = &nextAttemptAt
+	}
+
+	if delivery.DeliveredAt != nil {
+		deliveredAt := delivery.DeliveredAt.UTC().Format("2006-01-02T15:04:05.000Z")
+		deliveryResponse.DeliveredAt = &deliveredAt
+	}
+
+	return deliveryResponse
+}
@@ -0,0 +1,104 @@
+// Package moderation screens user-submitted content before it is published.
+package moderation
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var ErrRejected = errors.New("contains disallowed content")
+
+//go:embed blocklist.txt
+var defaultBlocklist string
+
+// ContentFilter decides whether text may be published.
+type ContentFilter interface {
+	// Check returns ErrRejected when any of texts must not be published.
+	Check(texts ...string) error
+}
+
+// New builds the filter of the given kind ("keywords" or "none"). The
+// keyword filter reads the blocklist at path, falling back to the one
+// shipped with the binary.
+func New(kind, path string) (ContentFilter, error) {
+	switch kind {
+	case "keywords":
+		if path == "" {
+			return NewKeywordFilter(strings.NewReader(defaultBlocklist))
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return NewKeywordFilter(f)
+	case "none":
+		return NopFilter{}, nil
+	default:
+		return nil, fmt.Errorf("Unknown CONTENT_FILTER: %s", kind)
+	}
+}
+
+// NopFilter accepts everything.
+type NopFilter struct{}
+
+func (NopFilter) Check(texts ...string) error {
+	return nil
+}
+
+// KeywordFilter rejects text matching any rule of a blocklist.
+type KeywordFilter struct {
+	rules []*regexp.Regexp
+}
+
+// NewKeywordFilter parses a blocklist with one rule per line. Lines starting
+// with "re:" are regular expressions, any other line is a keyword or phrase
+// matched on word boundaries. Both are case insensitive; blank lines and
+// lines starting with "#" are ignored.
+func NewKeywordFilter(r io.Reader) (*KeywordFilter, error) {
+	filter := &KeywordFilter{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		rule := strings.TrimSpace(scanner.Text())
+		if rule == "" || strings.HasPrefix(rule, "#") {
+			continue
+		}
+
+		var pattern string
+		if strings.HasPrefix(rule, "re:") {
+			pattern = "(?i)" + strings.TrimPrefix(rule, "re:")
+		} else {
+			pattern = `(?i)\b` + regexp.QuoteMeta(rule) + `\b`
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("blocklist line %d: %s", line, err)
+		}
+		filter.rules = append(filter.rules, re)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+func (f *KeywordFilter) Check(texts ...string) error {
+	for _, text := range texts {
+		for _, rule := range f.rules {
+			if rule.MatchString(text) {
+				return ErrRejected
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Message is one event pushed to subscribers of Topic.
+type Message struct {
+	Topic string          `json:"topic"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Hub fans published messages out to the subscribers of their topic.
+type Hub interface {
+	Publish(topic, event string, data interface{}) error
+	Subscribe(topics ...string) *Subscription
+	// Close ends every subscription.
+	Close() error
+}
+
+// New builds the hub selected by backend: "memory" only reaches clients of
+// this instance, "postgres" uses LISTEN/NOTIFY on postgresURL to reach all
+// of them.
+func New(backend, postgresURL string) (Hub, error) {
+	switch backend {
+	case "memory":
+		return NewMemoryHub(), nil
+	case "postgres":
+		return NewPostgresHub(postgresURL)
+	default:
+		return nil, fmt.Errorf("Unknown REALTIME_BACKEND: %s", backend)
+	}
+}
+
+// SubscriptionsChanged is published to a user's topic when the authors or
+// tags they follow change, so that their open streams resubscribe.
+const SubscriptionsChanged = "subscriptions.changed"
+
+// Messages carry references rather than the content itself, which keeps them
+// under the NOTIFY payload limit; clients fetch the rest from the API.
+
+type ArticleRef struct {
+	Slug   string `json:"slug"`
+	Author string `json:"author"`
+}
+
+type CommentRef struct {
+	ID      uint   `json:"id"`
+	Article string `json:"article"`
+}
+
+type NotificationRef struct {
+	ID   uint   `json:"id"`
+	Kind string `json:"kind"`
+}
+
+func ArticleTopic(slug string) string {
+	return "article:" + slug
+}
+
+func AuthorTopic(userID uint) string {
+	return fmt.Sprintf("author:%d", userID)
+}
+
+func TagTopic(name string) string {
+	return "tag:" + name
+}
+
+func UserTopic(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
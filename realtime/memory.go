@@ -0,0 +1,107 @@
+package realtime
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+const subscriptionBuffer = 32
+
+// Subscription receives the messages of its topics on C until closed.
+type Subscription struct {
+	C <-chan *Message
+
+	c      chan *Message
+	topics []string
+	hub    *MemoryHub
+	once   sync.Once
+}
+
+func (sub *Subscription) Close() {
+	sub.hub.unsubscribe(sub)
+}
+
+// MemoryHub is an in-process Hub.
+type MemoryHub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription]struct{}
+	closed bool
+}
+
+func NewMemoryHub() *MemoryHub {
+	return &MemoryHub{
+		topics: map[string]map[*Subscription]struct{}{},
+	}
+}
+
+func (hub *MemoryHub) Publish(topic, event string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	hub.Dispatch(&Message{Topic: topic, Event: event, Data: encoded})
+	return nil
+}
+
+// Dispatch delivers msg to local subscribers. Slow subscribers whose buffer
+// is full miss the message rather than blocking the publisher.
+func (hub *MemoryHub) Dispatch(msg *Message) {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+
+	for sub := range hub.topics[msg.Topic] {
+		select {
+		case sub.c <- msg:
+		default:
+		}
+	}
+}
+
+func (hub *MemoryHub) Subscribe(topics ...string) *Subscription {
+	c := make(chan *Message, subscriptionBuffer)
+	sub := &Subscription{C: c, c: c, topics: topics, hub: hub}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if hub.closed {
+		close(c)
+		return sub
+	}
+
+	for _, topic := range topics {
+		if hub.topics[topic] == nil {
+			hub.topics[topic] = map[*Subscription]struct{}{}
+		}
+		hub.topics[topic][sub] = struct{}{}
+	}
+	return sub
+}
+
+func (hub *MemoryHub) unsubscribe(sub *Subscription) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	for _, topic := range sub.topics {
+		delete(hub.topics[topic], sub)
+		if len(hub.topics[topic]) == 0 {
+			delete(hub.topics, topic)
+		}
+	}
+	sub.once.Do(func() { close(sub.c) })
+}
+
+func (hub *MemoryHub) Close() error {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	hub.closed = true
+	for topic, subs := range hub.topics {
+		for sub := range subs {
+			sub.once.Do(func() { close(sub.c) })
+		}
+		delete(hub.topics, topic)
+	}
+	return nil
+}
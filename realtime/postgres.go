@@ -0,0 +1,89 @@
+package realtime
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const postgresChannel = "conduit_realtime"
+
+// maxNotifyPayload is the largest payload NOTIFY accepts: it must be shorter
+// than 8000 bytes.
+const maxNotifyPayload = 7999
+
+var ErrPayloadTooLarge = errors.New("realtime message exceeds the NOTIFY payload limit")
+
+// PostgresHub relays messages through Postgres LISTEN/NOTIFY so that every
+// instance's subscribers receive them. NOTIFY payloads are limited to 8000
+// bytes, so Publish refuses larger messages with ErrPayloadTooLarge.
+type PostgresHub struct {
+	*MemoryHub
+
+	db       *sql.DB
+	listener *pq.Listener
+}
+
+func NewPostgresHub(url string) (*PostgresHub, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+
+	listener := pq.NewListener(url, time.Second, time.Minute, nil)
+	if err := listener.Listen(postgresChannel); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	hub := &PostgresHub{
+		MemoryHub: NewMemoryHub(),
+		db:        db,
+		listener:  listener,
+	}
+	go hub.listen()
+	return hub, nil
+}
+
+func (hub *PostgresHub) Publish(topic, event string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(&Message{Topic: topic, Event: event, Data: encoded})
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxNotifyPayload {
+		return ErrPayloadTooLarge
+	}
+
+	_, err = hub.db.Exec("SELECT pg_notify($1, $2)", postgresChannel, string(payload))
+	return err
+}
+
+func (hub *PostgresHub) listen() {
+	for notification := range hub.listener.Notify {
+		// A nil notification means the connection was re-established and
+		// messages may have been missed; there is nothing to replay.
+		if notification == nil {
+			continue
+		}
+
+		var msg Message
+		if err := json.Unmarshal([]byte(notification.Extra), &msg); err != nil {
+			continue
+		}
+		hub.Dispatch(&msg)
+	}
+}
+
+func (hub *PostgresHub) Close() error {
+	hub.listener.Close()
+	hub.MemoryHub.Close()
+	return hub.db.Close()
+}
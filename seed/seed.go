@@ -0,0 +1,221 @@
+// Package seed fills a development database with fake but deterministic
+// users, follows, articles, comments and favorites.
+package seed
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/koyoyo/realworld-starter-kit/models"
+)
+
+// Options sets the volume of generated data. The same Seed always produces
+// the same data.
+type Options struct {
+	Seed               int64
+	Users              int
+	ArticlesPerUser    int
+	FollowsPerUser     int
+	FavoritesPerUser   int
+	CommentsPerArticle int
+	Password           string
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Seed:               1,
+		Users:              20,
+		ArticlesPerUser:    5,
+		FollowsPerUser:     5,
+		FavoritesPerUser:   10,
+		CommentsPerArticle: 3,
+		Password:           "password",
+	}
+}
+
+// articleAgeHours bounds how far back generated articles are dated.
+const articleAgeHours = 90 * 24
+
+// Summary counts what Run created.
+type Summary struct {
+	Users     int
+	Follows   int
+	Articles  int
+	Comments  int
+	Favorites int
+}
+
+var (
+	adjectives = []string{"brave", "calm", "eager", "fancy", "gentle", "happy", "jolly", "kind", "lively", "merry",
+		"nimble", "proud", "quiet", "rapid", "sunny", "tidy", "vivid", "witty", "young", "zesty"}
+	nouns = []string{"badger", "comet", "dolphin", "falcon", "garden", "harbor", "island", "jaguar", "lantern",
+		"meadow", "otter", "pebble", "river", "sparrow", "tiger", "valley", "willow", "yak", "zephyr", "maple"}
+	topics = []string{"go", "postgres", "testing", "design", "devops", "security", "frontend", "performance",
+		"career", "open-source", "databases", "tutorial"}
+	words = []string{"the", "a", "system", "build", "fast", "simple", "code", "review", "deploy", "query", "index",
+		"cache", "request", "handler", "team", "learn", "write", "read", "small", "large", "change", "debug",
+		"service", "feature", "reliable", "data", "model", "layer", "clean", "api", "user", "release"}
+)
+
+// generator wraps the seeded source so every helper draws from it in order.
+type generator struct {
+	rand *rand.Rand
+}
+
+func (g *generator) pick(list []string) string {
+	return list[g.rand.Intn(len(list))]
+}
+
+func (g *generator) sentence(min, max int) string {
+	n := min + g.rand.Intn(max-min+1)
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = g.pick(words)
+	}
+	s := strings.Join(parts, " ")
+	return strings.ToUpper(s[:1]) + s[1:] + "."
+}
+
+func (g *generator) title() string {
+	parts := strings.Fields(g.sentence(3, 7))
+	for i, part := range parts {
+		parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	return strings.TrimSuffix(strings.Join(parts, " "), ".")
+}
+
+func (g *generator) body() string {
+	var b strings.Builder
+	for i, paragraphs := 0, 2+g.rand.Intn(4); i < paragraphs; i++ {
+		if i > 0 && g.rand.Intn(2) == 0 {
+			fmt.Fprintf(&b, "## %s\n\n", g.title())
+		}
+		for j, sentences := 0, 2+g.rand.Intn(5); j < sentences; j++ {
+			if j > 0 {
+				b.WriteString(" ")
+			}
+			b.WriteString(g.sentence(6, 16))
+		}
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
+
+func (g *generator) tags() []string {
+	var tags []string
+	seen := map[string]bool{}
+	for i, n := 0, 1+g.rand.Intn(3); i < n; i++ {
+		tag := g.pick(topics)
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Run generates the data through the models package so counters such as
+// FavoritesCount and CommentsCount and the domain events stay consistent
+// with what the API would have produced.
+//
+// Running it again with the same seed adds nothing: users, follows and
+// favorites that exist are kept, and an author's article with the generated
+// title is reused along with its comments. Only newly created articles are
+// dated relative to the current run.
+func Run(db *models.DB, options Options) (*Summary, error) {
+	g := &generator{rand: rand.New(rand.NewSource(options.Seed))}
+	summary := &Summary{}
+
+	var userIDs []uint
+	for i := 0; i < options.Users; i++ {
+		username := fmt.Sprintf("%s_%s_%d", g.pick(adjectives), g.pick(nouns), i+1)
+		bio := g.sentence(4, 10)
+
+		user := db.GetUserFromUsername(username).User
+		if user.ID == 0 {
+			user = db.CreateUser(username, username+"@example.com", options.Password).User
+			db.UpdateUser(&user, "", "", "", bio, nil)
+			summary.Users++
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+	if len(userIDs) == 0 {
+		return summary, nil
+	}
+
+	for _, followerID := range userIDs {
+		for i := 0; i < options.FollowsPerUser && i < len(userIDs)-1; i++ {
+			followingID := userIDs[g.rand.Intn(len(userIDs))]
+			if followingID == followerID || db.IsFollowing(followerID, followingID) {
+				continue
+			}
+			db.Follow(followerID, followingID)
+			summary.Follows++
+		}
+	}
+
+	// Dates are spread over the past few months so sorting and trending
+	// have something to work with; slugs are made unique like imports.
+	now := time.Now()
+	var articles []*models.Article
+	created := map[uint]bool{}
+	for _, authorID := range userIDs {
+		existing := map[string][]*models.Article{}
+		for _, article := range db.ListAuthorArticles(authorID) {
+			existing[article.Title] = append(existing[article.Title], article)
+		}
+
+		for i := 0; i < options.ArticlesPerUser; i++ {
+			// Everything is drawn even for a reused article so that what
+			// follows is generated the same way.
+			date := now.Add(-time.Duration(g.rand.Intn(articleAgeHours)) * time.Hour)
+			title, description, body, tags := g.title(), g.sentence(5, 12), g.body(), g.tags()
+
+			if reused := existing[title]; len(reused) > 0 {
+				articles = append(articles, reused[0])
+				existing[title] = reused[1:]
+				continue
+			}
+
+			article, err := db.ImportArticle(authorID, title, description, body, tags, "", date)
+			if err != nil {
+				return summary, err
+			}
+			articles = append(articles, article)
+			created[article.ID] = true
+			summary.Articles++
+		}
+	}
+	if len(articles) == 0 {
+		return summary, nil
+	}
+
+	for _, article := range articles {
+		for i := 0; i < options.CommentsPerArticle; i++ {
+			authorID := userIDs[g.rand.Intn(len(userIDs))]
+			text := g.sentence(4, 20)
+			if created[article.ID] {
+				if _, err := db.AddArticleComment(article, authorID, text); err != nil {
+					return summary, err
+				}
+				summary.Comments++
+			}
+		}
+	}
+
+	for _, userID := range userIDs {
+		for i := 0; i < options.FavoritesPerUser; i++ {
+			article := articles[g.rand.Intn(len(articles))]
+			if article.AuthorID == userID || db.IsFavorite(article.ID, userID) {
+				continue
+			}
+			if _, err := db.FavoriteArticle(article.ID, userID); err == nil {
+				summary.Favorites++
+			}
+		}
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	store := NewLocalStore(t.TempDir(), "/media")
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "avatars/1/a.png", strings.NewReader("png"), 3, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	r, err := store.Get(ctx, "avatars/1/a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "png" {
+		t.Errorf("Get = %q, want %q", data, "png")
+	}
+	if got := store.URL("avatars/1/a.png"); got != "/media/avatars/1/a.png" {
+		t.Errorf("URL = %q", got)
+	}
+
+	if err := store.Delete(ctx, "avatars/1/a.png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "avatars/1/a.png"); err != ErrNotFound {
+		t.Errorf("Get after Delete: %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "avatars/1/a.png"); err != nil {
+		t.Errorf("deleting twice: %s", err)
+	}
+}
+
+func TestLocalStoreHandlerDoesNotListDirectories(t *testing.T) {
+	store := NewLocalStore(t.TempDir(), "/media")
+	store.Put(context.Background(), "avatars/1/a.png", strings.NewReader("png"), 3, "image/png")
+	handler := http.StripPrefix("/media/", store.Handler())
+
+	for path, want := range map[string]int{
+		"/media/avatars/1/a.png":  http.StatusOK,
+		"/media/":                 http.StatusNotFound,
+		"/media/avatars":          http.StatusNotFound,
+		"/media/avatars/1/":       http.StatusNotFound,
+		"/media/avatars/1/b.png":  http.StatusNotFound,
+		"/media/../../etc/passwd": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is a stand-in for an S3 endpoint, speaking just enough of the API
+// for S3Store: path-style PUT, GET, HEAD and DELETE of objects in one bucket.
+type fakeS3 struct {
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, ok := r.URL.Query()["location"]; ok {
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>`+
+			`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`)
+		return
+	}
+
+	prefix := "/" + f.bucket + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.Error(w, "unknown bucket", http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case "PUT":
+		data, err := readPayload(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = fakeObject{data: data, contentType: r.Header.Get("Content-Type")}
+		w.Header().Set("ETag", etag(data))
+	case "GET", "HEAD":
+		object, ok := f.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == "GET" {
+				fmt.Fprintf(w, `<Error><Code>NoSuchKey</Code><Message>not found</Message><Key>%s</Key></Error>`, key)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.data)))
+		w.Header().Set("ETag", etag(object.data))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		if r.Method == "GET" {
+			w.Write(object.data)
+		}
+	case "DELETE":
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+	}
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// readPayload reads a PUT body, undoing the aws-chunked framing the client
+// uses to sign uploads sent over plain HTTP.
+func readPayload(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data []byte
+	body := bufio.NewReader(r.Body)
+	for {
+		header, err := body.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex := strings.TrimSpace(strings.SplitN(header, ";", 2)[0])
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		chunk := make([]byte, size+2) // the chunk and its trailing CRLF
+		if _, err := io.ReadFull(body, chunk); err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data, nil
+		}
+		data = append(data, chunk[:size]...)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{bucket: "media", objects: map[string]fakeObject{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+	store, err := NewS3Store(endpoint, "access", "secret", "media", false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	body := strings.Repeat("image bytes ", 1000)
+	if err := store.Put(ctx, "images/1/a.png", strings.NewReader(body), int64(len(body)), "image/png"); err != nil {
+		t.Fatalf("Put: %s", err)
+	}
+	if object := fake.objects["images/1/a.png"]; string(object.data) != body || object.contentType != "image/png" {
+		t.Errorf("stored %d bytes of %q, want %d bytes of image/png", len(object.data), object.contentType, len(body))
+	}
+
+	r, err := store.Get(ctx, "images/1/a.png")
+	if err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != body {
+		t.Errorf("Get returned %d bytes (%v), want %d", len(data), err, len(body))
+	}
+
+	if got, want := store.URL("images/1/a.png"), "http://"+endpoint+"/media/images/1/a.png"; got != want {
+		t.Errorf("URL = %q, want %q", got, want)
+	}
+
+	if err := store.Delete(ctx, "images/1/a.png"); err != nil {
+		t.Fatalf("Delete: %s", err)
+	}
+	if _, err := store.Get(ctx, "images/1/a.png"); err != ErrNotFound {
+		t.Errorf("Get after Delete: %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/koyoyo/realworld-starter-kit/config"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps uploaded files. Keys are slash separated paths such as
+// "avatars/12/abc.png".
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// URL returns where clients can download the blob.
+	URL(key string) string
+}
+
+// New builds the store selected by the backend ("local" or "s3").
+func New(cfg config.Storage) (BlobStore, error) {
+	switch cfg.Backend {
+	case "local":
+		return NewLocalStore(cfg.MediaRoot, cfg.MediaURL), nil
+	case "s3":
+		return NewS3Store(cfg.S3Endpoint, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3Bucket, cfg.S3UseSSL, cfg.MediaURL)
+	default:
+		return nil, fmt.Errorf("Unknown BLOB_STORE: %s", cfg.Backend)
+	}
+}
@@ -0,0 +1,72 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when a webhook would reach the server's own
+// network: loopback, private, link-local or unspecified addresses.
+var ErrForbiddenAddress = errors.New("webhook address is not publicly routable")
+
+// ValidateURL accepts absolute http and https URLs whose host is not a
+// forbidden IP literal. Hostnames are checked again when dialing, since DNS
+// can change between registration and delivery.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https, not %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return errors.New("host is required")
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && forbiddenIP(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+func forbiddenIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// refuseForbidden runs after name resolution, right before each connection,
+// so it sees the address actually dialed, including after redirects.
+func refuseForbidden(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || forbiddenIP(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// newClient returns a client that can only connect to public addresses. It
+// ignores proxy settings, which would otherwise be what gets dialed.
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: refuseForbidden,
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
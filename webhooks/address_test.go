@@ -0,0 +1,43 @@
+package webhooks
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestValidateURL(t *testing.T) {
+	for raw, ok := range map[string]bool{
+		"https://example.com/hook":                  true,
+		"http://hooks.example.com:8080/x":           true,
+		"ftp://example.com/hook":                    false,
+		"file:///etc/passwd":                        false,
+		"http://127.0.0.1/hook":                     false,
+		"http://[::1]/hook":                         false,
+		"http://10.0.0.8/hook":                      false,
+		"http://192.168.1.1/hook":                   false,
+		"http://169.254.169.254/latest/meta-data/":  false,
+		"http://[::ffff:169.254.169.254]/meta-data": false,
+		"http://0.0.0.0/hook":                       false,
+	} {
+		if err := ValidateURL(raw); (err == nil) != ok {
+			t.Errorf("ValidateURL(%q) = %v, want ok=%v", raw, err, ok)
+		}
+	}
+}
+
+// The client refuses forbidden addresses by itself, as it must for hostnames
+// that only resolve to one at delivery time.
+func TestClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback server")
+	}))
+	defer server.Close()
+
+	_, err := newClient(time.Second).Get(server.URL)
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("Get(%s) = %v, want ErrForbiddenAddress", server.URL, err)
+	}
+}
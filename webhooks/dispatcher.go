@@ -0,0 +1,105 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/koyoyo/realworld-starter-kit/models"
+)
+
+// Dispatcher drains the webhook delivery queue.
+type Dispatcher struct {
+	DB        *models.DB
+	Client    *http.Client
+	Interval  time.Duration
+	BatchSize int
+}
+
+func NewDispatcher(db *models.DB) *Dispatcher {
+	return &Dispatcher{
+		DB:        db,
+		Client:    newClient(10 * time.Second),
+		Interval:  5 * time.Second,
+		BatchSize: 20,
+	}
+}
+
+// Run polls for due deliveries until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		d.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		deliveries := d.DB.ClaimWebhookDeliveries(d.BatchSize)
+		if len(deliveries) == 0 {
+			return
+		}
+
+		for _, delivery := range deliveries {
+			d.send(ctx, delivery)
+		}
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery *models.WebhookDelivery) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	payload := []byte(delivery.Payload)
+
+	if err := ValidateURL(delivery.Webhook.URL); err != nil {
+		d.DB.RecordWebhookAttempt(delivery, 0, err)
+		return
+	}
+
+	req, err := http.NewRequest("POST", delivery.Webhook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		d.DB.RecordWebhookAttempt(delivery, 0, err)
+		return
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Conduit-Webhooks")
+	req.Header.Set("X-Conduit-Event", delivery.Event)
+	req.Header.Set("X-Conduit-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Conduit-Timestamp", timestamp)
+	req.Header.Set("X-Conduit-Signature", "sha256="+Sign(delivery.Webhook.Secret, timestamp, payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		d.DB.RecordWebhookAttempt(delivery, 0, err)
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	d.DB.RecordWebhookAttempt(delivery, resp.StatusCode, nil)
+}
+
+// Sign returns the hex HMAC-SHA256 of "timestamp.payload" keyed by secret.
+// Receivers recompute it to check the X-Conduit-Signature header, and should
+// reject stale timestamps to prevent replays.
+func Sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}